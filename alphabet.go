@@ -0,0 +1,167 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// rtlScripts are the scripts written right to left.
+var rtlScripts = []*unicode.RangeTable{
+	unicode.Arabic, unicode.Hebrew, unicode.Syriac, unicode.Thaana, unicode.Nko,
+	unicode.Samaritan, unicode.Mandaic, unicode.Adlam, unicode.Hanifi_Rohingya,
+}
+
+// bidiSymbols lists the symbols, as U+XXXX, that are right-to-left letters
+// or invisible format characters such as bidirectional controls. Display
+// engines may reorder or hide them, which corrupts copied output.
+func bidiSymbols(alphabet string) []string {
+	var found []string
+	for _, r := range alphabet {
+		if unicode.In(r, rtlScripts...) || unicode.Is(unicode.Cf, r) {
+			found = append(found, fmt.Sprintf("%U", r))
+		}
+	}
+	return found
+}
+
+// checkUppercase fails if some symbol would be changed by a transport that
+// upper-cases everything.
+func checkUppercase(alphabet string) error {
+	var changed []string
+	for _, r := range alphabet {
+		if unicode.ToUpper(r) != r {
+			changed = append(changed, fmt.Sprintf("%c", r))
+		}
+	}
+	if len(changed) > 0 {
+		return fmt.Errorf("alphabet is not all uppercase (%s); upper-casing transports would corrupt the output", strings.Join(changed, " "))
+	}
+	return nil
+}
+
+// caseCollisions lists the symbol pairs that only differ in case and so
+// become indistinguishable under case folding.
+func caseCollisions(alphabet string) []string {
+	symbols := []rune(alphabet)
+	var pairs []string
+	for i, a := range symbols {
+		for _, b := range symbols[i+1:] {
+			if strings.EqualFold(string(a), string(b)) {
+				pairs = append(pairs, fmt.Sprintf("%c/%c", a, b))
+			}
+		}
+	}
+	return pairs
+}
+
+// alias is an alternative spelling of an alphabet symbol, such as oe for
+// Ö when a transport cannot carry umlauts.
+type alias struct {
+	seq    []byte
+	symbol rune
+}
+
+// parseAliases parses a list like "Ö=oe,Ä=ae". A spelling may not contain
+// alphabet symbols, so it can never occur in regular data. Longer aliases
+// are tried first.
+func parseAliases(list string, decodeMap map[rune]byte) ([]alias, error) {
+	var aliases []alias
+	seen := make(map[string]bool)
+	for _, entry := range strings.Split(list, ",") {
+		symbol, seq, ok := strings.Cut(entry, "=")
+		if !ok || utf8.RuneCountInString(symbol) != 1 || seq == "" {
+			return nil, fmt.Errorf("bad entry %q, want SYMBOL=SPELLING", entry)
+		}
+		r, _ := utf8.DecodeRuneInString(symbol)
+		if _, ok := decodeMap[r]; !ok {
+			return nil, fmt.Errorf("%q is not an alphabet symbol", symbol)
+		}
+		// Spellings made of symbols would take over encoded data, e.g.
+		// OE for Ö would read the symbols O and E as one Ö
+		for _, c := range seq {
+			if _, ok := decodeMap[c]; ok {
+				return nil, fmt.Errorf("alias %q contains the alphabet symbol %q", seq, c)
+			}
+		}
+		if strings.ContainsAny(seq, "\r\n") {
+			return nil, fmt.Errorf("alias %q contains a line break", seq)
+		}
+		if seen[seq] {
+			return nil, fmt.Errorf("alias %q is given twice", seq)
+		}
+		seen[seq] = true
+		aliases = append(aliases, alias{seq: []byte(seq), symbol: r})
+	}
+	sort.SliceStable(aliases, func(i, j int) bool {
+		return len(aliases[i].seq) > len(aliases[j].seq)
+	})
+	return aliases, nil
+}
+
+// foldRune maps the fullwidth forms of ASCII characters, as produced by
+// some East Asian input methods and transforms, to ASCII.
+func foldRune(r rune) rune {
+	if r >= 0xFF01 && r <= 0xFF5E {
+		return r - 0xFEE0
+	}
+	return r
+}
+
+// foldSymbol returns the alphabet symbol that r stands for under -fold:
+// after foldRune, any case variant of a symbol is accepted.
+func foldSymbol(r rune, decodeMap map[rune]byte) (rune, bool) {
+	r = foldRune(r)
+	if _, ok := decodeMap[r]; ok {
+		return r, true
+	}
+	for f := unicode.SimpleFold(r); f != r; f = unicode.SimpleFold(f) {
+		if _, ok := decodeMap[f]; ok {
+			return f, true
+		}
+	}
+	return r, false
+}
+
+// foldCollisions lists symbol pairs that -fold would merge, formatted as
+// "a/b".
+func foldCollisions(alphabet string) []string {
+	symbols := []rune(alphabet)
+	var pairs []string
+	for i, a := range symbols {
+		for _, b := range symbols[i+1:] {
+			if strings.EqualFold(string(foldRune(a)), string(foldRune(b))) {
+				pairs = append(pairs, fmt.Sprintf("%c/%c", a, b))
+			}
+		}
+	}
+	return pairs
+}
+
+// altMaps builds the maps for -alt-alphabets "EVEN;ODD": the bytes at
+// even indexes of the input are written with the first alphabet and those
+// at odd indexes with the second, so a repeated byte alternates.
+// The two must not share a symbol, so that every symbol names its digit
+// on its own. The returned encode and decode maps hold the even alphabet
+// and the union of both; oddEncode and oddDecode the odd alphabet alone.
+func altMaps(spec string) (alphabet string, encodeMap map[byte]rune, decodeMap map[rune]byte, oddEncode map[byte]rune, oddDecode map[rune]byte, err error) {
+	even, odd, ok := strings.Cut(spec, ";")
+	if !ok || strings.Contains(odd, ";") {
+		return "", nil, nil, nil, nil, fmt.Errorf("want two alphabets separated by ';'")
+	}
+	if encodeMap, decodeMap, err = createMaps(even); err != nil {
+		return "", nil, nil, nil, nil, fmt.Errorf("first alphabet: %w", err)
+	}
+	if oddEncode, oddDecode, err = createMaps(odd); err != nil {
+		return "", nil, nil, nil, nil, fmt.Errorf("second alphabet: %w", err)
+	}
+	for r, digit := range oddDecode {
+		if _, shared := decodeMap[r]; shared {
+			return "", nil, nil, nil, nil, fmt.Errorf("symbol %q is in both alphabets", r)
+		}
+		decodeMap[r] = digit
+	}
+	return even + odd, encodeMap, decodeMap, oddEncode, oddDecode, nil
+}
@@ -0,0 +1,111 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestParseAliases(t *testing.T) {
+	_, decodeMap, err := createMaps(defaultAlphabet)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		list    string
+		want    []string // spellings, longest first
+		wantErr bool
+	}{
+		{"Ö=oe,Ä=ae", []string{"oe", "ae"}, false},
+		{"ẞ=ss,Ü=ue,Ü=u:", []string{"ss", "ue", "u:"}, false},
+		{"Ö=o,Ä=ae", []string{"ae", "o"}, false},
+		{"Ö=A", nil, true},
+		{"Ö=OE", nil, true},
+		{"Ö=oE", nil, true},
+		{"o=oe", nil, true},
+		{"Ö=oe,Ä=oe", nil, true},
+		{"Ö=", nil, true},
+		{"Ö", nil, true},
+		{"Ö=o\ne", nil, true},
+	}
+	for _, tt := range tests {
+		aliases, err := parseAliases(tt.list, decodeMap)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("%q: no error", tt.list)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%q: %v", tt.list, err)
+			continue
+		}
+		var got []string
+		for _, a := range aliases {
+			got = append(got, string(a.seq))
+		}
+		if strings.Join(got, ",") != strings.Join(tt.want, ",") {
+			t.Errorf("%q: spellings %q, want %q", tt.list, got, tt.want)
+		}
+	}
+}
+
+func TestAliasDecode(t *testing.T) {
+	encodeMap, decodeMap, err := createMaps(defaultAlphabet)
+	if err != nil {
+		t.Fatal(err)
+	}
+	aliases, err := parseAliases("Ö=oe,Ä=ae,Ü=ue,ẞ=ss", decodeMap)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data := []byte{0xff, 0xe4, 0xfc, 0x80}
+	encoded := encodeString(t, data, encodeMap, encodeOptions{spb: 2})
+	spelled := strings.NewReplacer("Ö", "oe", "Ä", "ae", "Ü", "ue", "ẞ", "ss").Replace(encoded)
+	decoded, err := decodeString(spelled, decodeMap, decodeOptions{overflow: "error", spb: 2, quiet: true, aliases: aliases})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(decoded, data) {
+		t.Errorf("%q decoded as %v, want %v", spelled, decoded, data)
+	}
+}
+
+func TestAltMaps(t *testing.T) {
+	const even = "ABCDEFGHIJKLMNOPQRSTUVWXYZ0123"
+	const odd = "abcdefghijklmnopqrstuvwxyz4567"
+	tests := []struct {
+		name string
+		data []byte
+		want string
+	}{
+		{"repeated byte alternates", []byte{1, 1, 1}, "BAbaBA"},
+		{"single byte", []byte{0}, "AA"},
+		{"odd index", []byte{0, 31}, "AAbb"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, encodeMap, decodeMap, oddEncode, oddDecode, err := altMaps(even + ";" + odd)
+			if err != nil {
+				t.Fatal(err)
+			}
+			encoded := encodeString(t, tt.data, encodeMap, encodeOptions{spb: 2, oddMap: oddEncode})
+			if encoded != tt.want {
+				t.Errorf("encoded %v as %q, want %q", tt.data, encoded, tt.want)
+			}
+			decoded, err := decodeString(encoded, decodeMap, decodeOptions{overflow: "error", spb: 2, quiet: true, oddMap: oddDecode})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(decoded, tt.data) {
+				t.Errorf("%q decoded as %v, want %v", encoded, decoded, tt.data)
+			}
+		})
+	}
+
+	for _, spec := range []string{even, even + ";" + even, even + ";" + odd + ";" + odd, even + ";abc"} {
+		if _, _, _, _, _, err := altMaps(spec); err == nil {
+			t.Errorf("%q: no error", spec)
+		}
+	}
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"sync/atomic"
+	"time"
+)
+
+// budgetExpired is set once the -time-budget has run out. The codec loops
+// poll it and stop at the next byte boundary.
+var budgetExpired atomic.Bool
+
+// budgetDone is closed once the -time-budget has run out, to wake up reads
+// that are blocked waiting for input.
+var budgetDone = make(chan struct{})
+
+// startBudget arms the time budget.
+func startBudget(d time.Duration) {
+	time.AfterFunc(d, func() {
+		budgetExpired.Store(true)
+		close(budgetDone)
+	})
+}
+
+// reportBudget tells the user that processing was cut short.
+func reportBudget(totalBytes int) {
+	if !budgetExpired.Load() || *quietFlag {
+		return
+	}
+	fmt.Fprintf(os.Stderr, "Time budget of %v reached after %d bytes\n", *budgetFlag, totalBytes)
+}
+
+// readResult is the outcome of one Read of a budgetReader's source.
+type readResult struct {
+	n   int
+	err error
+}
+
+// budgetReader ends its input with io.EOF once the time budget has run
+// out, even while a read of r is blocked, for example on a stalled pipe.
+// Reads happen in a goroutine so they can be abandoned; an abandoned read
+// finishes in the background and its data is dropped.
+type budgetReader struct {
+	r       io.Reader
+	buf     []byte
+	results chan readResult
+}
+
+func newBudgetReader(r io.Reader) *budgetReader {
+	return &budgetReader{r: r, results: make(chan readResult, 1)}
+}
+
+func (b *budgetReader) Read(p []byte) (int, error) {
+	if budgetExpired.Load() {
+		return 0, io.EOF
+	}
+	if cap(b.buf) < len(p) {
+		b.buf = make([]byte, len(p))
+	}
+	buf := b.buf[:len(p)]
+	go func() {
+		n, err := b.r.Read(buf)
+		b.results <- readResult{n, err}
+	}()
+	select {
+	case res := <-b.results:
+		if res.err != nil && budgetExpired.Load() {
+			// The read failed because the budget cancelled it, as
+			// -url does with its request
+			res.err = io.EOF
+		}
+		return copy(p, buf[:res.n]), res.err
+	case <-budgetDone:
+		return 0, io.EOF
+	}
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"testing"
+	"time"
+)
+
+// resetBudget clears the time budget state left over by a test, and
+// silences the report of the budget running out.
+func resetBudget(t *testing.T) {
+	quiet := *quietFlag
+	*quietFlag = true
+	t.Cleanup(func() {
+		*quietFlag = quiet
+		budgetExpired.Store(false)
+		budgetDone = make(chan struct{})
+	})
+}
+
+// A read blocked on a stalled input must not keep the codec past the
+// budget.
+func TestBudgetStalledInput(t *testing.T) {
+	encodeMap, decodeMap, err := createMaps(defaultAlphabet)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name   string
+		decode bool
+		input  string // written before the input stalls
+		want   string
+	}{
+		{"encode nothing", false, "", ""},
+		{"encode some", false, "ab", encodeString(t, []byte("ab"), encodeMap, encodeOptions{spb: 2})},
+		{"decode some", true, encodeString(t, []byte("ab"), encodeMap, encodeOptions{spb: 2}), "ab"},
+		{"decode cut inside a pair", true, string([]rune{encodeMap[1], encodeMap[2], encodeMap[3]}), string([]byte{61})},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resetBudget(t)
+			r, w := io.Pipe()
+			defer w.Close()
+			go w.Write([]byte(tt.input))
+
+			var out bytes.Buffer
+			done := make(chan error, 1)
+			go func() {
+				reader := bufio.NewReader(newBudgetReader(r))
+				writer := bufio.NewWriter(&out)
+				var err error
+				if tt.decode {
+					err = decode(reader, writer, decodeMap, decodeOptions{overflow: "error", spb: 2, quiet: true})
+				} else {
+					err = encode(reader, writer, encodeMap, encodeOptions{spb: 2})
+				}
+				writer.Flush()
+				done <- err
+			}()
+
+			startBudget(50 * time.Millisecond)
+			select {
+			case err := <-done:
+				if err != nil {
+					t.Fatal(err)
+				}
+			case <-time.After(5 * time.Second):
+				t.Fatal("still reading 5s after a budget of 50ms")
+			}
+			if out.String() != tt.want {
+				t.Errorf("output %q, want %q", out.String(), tt.want)
+			}
+		})
+	}
+}
+
+// Without a budget running out, budgetReader passes everything through.
+func TestBudgetReader(t *testing.T) {
+	resetBudget(t)
+	data := bytes.Repeat([]byte("0123456789"), 100000)
+	got, err := io.ReadAll(newBudgetReader(bytes.NewReader(data)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, data) {
+		t.Errorf("read %d bytes, want %d", len(got), len(data))
+	}
+}
@@ -2,26 +2,130 @@ package main
 
 import (
 	"bufio"
+	"bytes"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
 	"flag"
 	"fmt"
+	"hash"
 	"io"
 	"os"
-	"time"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"unicode"
+	"unicode/utf8"
 )
 
 var (
 	decodeFlag = flag.Bool("d", false, "Decode mode")
 	helpFlag   = flag.Bool("h", false, "Show help")
 	widthFlag  = flag.Int("w", 0, "Number of encoded characters per line (0 for no wrapping)")
+
+	overflowFlag = flag.String("on-overflow", "error", "Handling of pairs above 255 in decode mode: error, wrap or skip")
+	fromHexFlag  = flag.Bool("from-hex", false, "Read hex digits instead of raw bytes in encode mode")
+	toHexFlag    = flag.Bool("to-hex", false, "Write hex digits instead of raw bytes in decode mode")
+	decimalFlag  = flag.Bool("decode-to-decimal", false, "Write decoded bytes as space-separated decimal numbers")
+	hexValFlag   = flag.Bool("decode-to-hex", false, "Write decoded bytes as space-separated hex numbers")
+	fromB64Flag  = flag.Bool("from-base64", false, "Read base64 instead of raw bytes in encode mode")
+	toB64Flag    = flag.Bool("to-base64", false, "Write base64 instead of raw bytes in decode mode")
+	continueFlag = flag.Bool("continue-on-error", false, "Keep going with the next input file after a failure; the output of a failed file is dropped")
+	quietFlag    = flag.Bool("q", false, "Suppress progress and summary messages")
+	previewFlag  = flag.Bool("preview", false, "Show the first encoded symbols on stderr in encode mode")
+	eolRawFlag   = flag.String("eol-raw", "", "Line separator for -w as an escaped byte sequence, e.g. \\x1e (default \\r\\n)")
+	delimFlag    = flag.String("message-delimiter", "", "Escaped byte sequence separating messages in decode mode; each message is written to its own file")
+	templateFlag = flag.String("out-template", messageTemplate, "File name template for -message-delimiter, with one numeric verb")
+	alphabetFlag = flag.String("a", defaultAlphabet, "Alphabet of 30 distinct symbols (overrides $CODE30_ALPHABET)")
+	presetFlag   = flag.String("preset", "", "Use a named alphabet: german or transcribe (see -list-presets)")
+	upperFlag    = flag.Bool("assert-uppercase", false, "Fail if the alphabet contains symbols that change when upper-cased")
+	foldFlag     = flag.Bool("fold-case-safe", false, "Warn if the alphabet contains symbols that differ only in case")
+	spbFlag      = flag.Int("spb", 2, "Symbols per byte, at least 2; every byte takes exactly this many")
+	indexFlag    = flag.String("index", "", "Write a CSV index of input and output offsets to this file in encode mode")
+	intervalFlag = flag.Int("index-interval", bufferSize, "Input bytes between -index entries, or decoded bytes between -offset-map entries")
+	finalEOLFlag = flag.Bool("final-eol", false, "Terminate a partial last line with the line separator too (with -w)")
+	lengthFlag   = flag.Bool("length-prefix", false, "Frame the data with its length, encoded as an 8-byte big-endian prefix")
+	autoGzipFlag = flag.Bool("auto-gzip", false, "Note gzip input when encoding; gunzip gzip data when decoding")
+	parallelFlag = flag.Bool("parallel-decode", false, "Decode wrapped or -block input in parallel segments split at line breaks and markers")
+	budgetFlag   = flag.Duration("time-budget", 0, "Stop cleanly after this long, e.g. 5s (0 for no limit)")
+	prefixFlag   = flag.String("prefix", "", "Literal alphabet symbols written before the data; decode checks and strips them")
+	memoryFlag   = flag.String("max-memory", "", "Fail instead of allocating more than this for buffers, e.g. 16M")
+	expectFlag   = flag.String("expect", "", "Check decoded output: utf8 fails on invalid UTF-8")
+	aliasFlag    = flag.String("alias", "", "Alternative spellings accepted in decode mode, e.g. Ö=oe,Ä=ae; they may not contain alphabet symbols")
+	pipelineFlag = flag.String("pipeline", "", "Byte transforms applied before encoding and undone after decoding, e.g. gzip,xor:KEY,encode")
+	entropyFlag  = flag.Bool("entropy", false, "Print the Shannon entropy of the input bytes in bits per byte")
+	rewrapFlag   = flag.Bool("rewrap", false, "Re-wrap encoded input at -w without decoding it")
+	perFileFlag  = flag.Bool("alphabet-per-file", false, "Decode each input file with the alphabet from its .meta sidecar")
+	statsFlag    = flag.String("symbol-stats", "", "Write the emitted symbol counts as JSON to this file")
+	outputFlag   = flag.String("o", "", "Write output to this file instead of stdout")
+	appendFlag   = flag.Bool("append", false, "Append to the -o file instead of truncating it")
+	selfTestFlag = flag.Bool("self-test", false, "Check the codec against built-in vectors before processing input")
+	encIntFlag   = flag.String("encode-int", "", "Encode a decimal integer and print the result")
+	decIntFlag   = flag.String("decode-int", "", "Decode a string produced by -encode-int and print the integer")
+	resumeFlag   = flag.Bool("resume", false, "Continue an interrupted encode into the -o file")
+	eccFlag      = flag.Bool("ecc", false, "Add check bytes that let decode repair one corrupted symbol per 32-byte block")
+	bufferedFlag = flag.Bool("buffered", false, "Read each input completely into memory before processing it")
+	reverseFlag  = flag.Bool("reverse", false, "Emit or read the symbol stream back to front; implies -buffered")
+	dictFlag     = flag.String("dict", "", "Replace the byte sequences listed in this file by short codes (one hex entry per line)")
+	numbersFlag  = flag.Bool("line-numbers", false, "Start each wrapped line with its number as \"NNN: \"; decode strips them")
+	checkFlag    = flag.Int("checkpoint", 0, "Write a ~ marker and flush the output after every N input bytes")
+	blockFlag    = flag.Int("block", 0, "Write a ~ marker after every N input bytes, where -parallel-decode may split unwrapped output")
+	replaceFlag  = flag.String("replace-unknown", "", "Decode groups with invalid characters as this byte, e.g. 0x00, instead of failing")
+	byteSepFlag  = flag.String("byte-delimiter", "", "Escaped string written between the symbol groups of each byte, e.g. \" \"")
+	ignoreFlag   = flag.String("ignore", "", "Escaped string skipped in decode mode, such as a -byte-delimiter")
+	inspectFlag  = flag.Bool("inspect", false, "Report on the encoded input instead of decoding it")
+	rotateFlag   = flag.String("rotate", "", "Start a new output file every interval, e.g. 1h")
+	rotNameFlag  = flag.String("rotate-name", rotateTemplate, "Time layout naming the -rotate files, before the .c30 suffix")
+	offsetFlag   = flag.String("offset-map", "", "Write a CSV map of encoded and decoded offsets to this file in decode mode")
+	normFlag     = flag.Bool("fold", false, "Accept fullwidth and other-case variants of the symbols in decode mode")
+	barFlag      = flag.Bool("progress-bar", false, "Show a progress bar with rate and ETA on a terminal when the input size is known")
+	speakFlag    = flag.Int("pronounceable", 0, "Write upper-case symbols in blocks of N joined by '-' for reading aloud; decode accepts them in any case")
+	configFlag   = flag.String("config", "", "Read flag values from a JSON object keyed by flag name; command-line flags take precedence")
+	explainFlag  = flag.Bool("explain", false, "Describe what the other flags would do and exit")
+	qrFlag       = flag.Int("qr-segments", 0, "Split the output into N segments with \"#QR i/N\" headers; decode reassembles them in any order")
+	trimFlag     = flag.Bool("trim-trailing-zeros", false, "Drop zero bytes at the end of each decoded message or of the output (lossy)")
+	strictFlag   = flag.Bool("strict", false, "Treat warnings about the alphabet as errors")
+	forceFlag    = flag.Bool("force", false, "Write decoded data even when stdout is a terminal")
+	startFlag    = flag.Int64("start-byte", 0, "Drop the first N decoded bytes")
+	windowFlag   = flag.Int64("length", -1, "Write at most N decoded bytes, after -start-byte")
+	linesCountFl = flag.Bool("count-lines", false, "Print the number of lines the -w wrapped output of the input will have and exit")
+	countFlag    = flag.Bool("count-symbols", false, "Estimate the encoded size and transcription time of the input and exit")
+	sizeFlag     = flag.String("size", "", "Input size in bytes for -count-symbols and -count-lines, with an optional K, M or G suffix")
+	spmFlag      = flag.Float64("spm", 60, "Transcription speed for -count-symbols in symbols per minute")
+	recordFlag   = flag.Int64("strict-length", 0, "Require the input when encoding, or the output when decoding, to be a multiple of N bytes")
+	padFlag      = flag.Bool("pad-final", false, "Fill the last line up to -w with '=', which decode skips")
+	idleFlag     = flag.Duration("idle", 0, "Encode each burst of stdin as a record on a line of its own, ending a record after a pause this long, e.g. 500ms; -index offsets restart with every record")
+	postCmdFlag  = flag.String("post-cmd", "", "Pipe the decoded bytes through this command before writing them in decode mode")
+	preCmdFlag   = flag.String("pre-cmd", "", "Pipe the input through this command before encoding it")
+	altFlag      = flag.String("alt-alphabets", "", "Two alphabets \"EVEN;ODD\" used for the bytes at even and odd indexes instead of -a; by byte, not symbol, so that a repeated byte alternates")
+	dedupFlag    = flag.Int("dedup-block", 0, "Encode repeated blocks of N input bytes as references to their first occurrence")
+	goFlag       = flag.String("go-literal", "", "Write the output as a Go declaration of this name: a string constant when encoding, a []byte when decoding")
+	maxLineFlag  = flag.Int("max-line-bytes", 0, "Fail on encoded lines longer than N bytes in decode mode (0 for no limit)")
+	inferFlag    = flag.Bool("infer", false, "Pick the preset whose alphabet matches the symbols of each input in decode mode")
+	listFlag     = flag.Bool("list-presets", false, "List the -preset names with their base and description, then exit")
+	checkCfgFlag = flag.Bool("check-config", false, "Check that all byte values round-trip with the alphabet and settings, then exit")
+	rulerFlag    = flag.Bool("ruler", false, "Start the wrapped output with a comment line numbering the columns")
+	urlFlag      = flag.String("url", "", "Read the input from this http or https URL instead of stdin")
+	digestFlag   = flag.String("digest-line", "", "End the output with a line holding the sha256 of the input; decode checks it")
+	execFlag     = flag.String("exec", "", "Pipe the decoded bytes into the stdin of this command instead of stdout")
+	manifestFlag = flag.String("manifest", "", "Write a JSON lines manifest of the -rotate output files to this file")
+	linesFlag    = flag.Int("lines", 0, "Spread the output evenly over exactly N lines instead of wrapping at -w")
+	jsonFlag     = flag.Bool("decode-to-json", false, "Wrap decoded bytes as base64 in a JSON object {\"bytes\":...,\"len\":N}")
 )
 
 const bufferSize = 1024 * 1024 // 1MB buffer
 
+// Code30: A-Z, ÄÖÜẞ (30 characters)
+const defaultAlphabet = "ABCDEFGHIJKLMNOPQRSTUVWXYZÄÖÜẞ"
+
 func usage() {
 	fmt.Fprintf(os.Stderr, "Encode binary data to German uppercase letters and back.\n\n")
-	fmt.Fprintf(os.Stderr, "Usage: %s [OPTIONS] < infile > outfile\n\n", os.Args[0])
+	fmt.Fprintf(os.Stderr, "Usage: %s [OPTIONS] < infile > outfile\n", os.Args[0])
+	fmt.Fprintf(os.Stderr, "       %s [OPTIONS] infile... > outfile\n\n", os.Args[0])
 	fmt.Fprintf(os.Stderr, "Options:\n")
 	flag.PrintDefaults()
+	fmt.Fprintf(os.Stderr, "\nEnvironment:\n")
+	fmt.Fprintf(os.Stderr, "  CODE30_ALPHABET\tdefault for -a; an explicit -a or -preset takes precedence\n")
 }
 
 func main() {
@@ -33,54 +137,1191 @@ func main() {
 		os.Exit(0)
 	}
 
-	encodeMap, decodeMap := createMaps()
+	if *configFlag != "" {
+		if err := applyConfig(*configFlag); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: invalid -config: %v\n", err)
+			os.Exit(1)
+		}
+	}
 
-	reader := bufio.NewReaderSize(os.Stdin, bufferSize)
-	writer := bufio.NewWriterSize(os.Stdout, bufferSize)
+	if err := validateFlags(); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
 
-	start := time.Now()
-	var err error
-	if *decodeFlag {
-		err = decode(reader, writer, decodeMap)
-	} else {
-		err = encode(reader, writer, encodeMap, *widthFlag)
+	if *listFlag {
+		if err := listPresets(os.Stdout); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		return
 	}
-	duration := time.Since(start)
 
+	switch *overflowFlag {
+	case "error", "wrap", "skip":
+	default:
+		fmt.Fprintf(os.Stderr, "Error: invalid -on-overflow mode %q (want error, wrap or skip)\n", *overflowFlag)
+		os.Exit(1)
+	}
+
+	alphabet := *alphabetFlag
+	if env := os.Getenv("CODE30_ALPHABET"); env != "" && !flagGiven("a") {
+		alphabet = env
+	}
+	if *presetFlag != "" {
+		var err error
+		if alphabet, err = lookupPreset(*presetFlag); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+	}
+	encodeMap, decodeMap, err := createMaps(alphabet)
 	if err != nil {
-		fmt.Fprintf(os.Stderr, "\nError: %v\n", err)
+		fmt.Fprintf(os.Stderr, "Error: invalid alphabet: %v\n", err)
+		os.Exit(1)
+	}
+	var oddEncode map[byte]rune
+	var oddDecode map[rune]byte
+	if *altFlag != "" {
+		if alphabet, encodeMap, decodeMap, oddEncode, oddDecode, err = altMaps(*altFlag); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: invalid -alt-alphabets: %v\n", err)
+			os.Exit(1)
+		}
+	}
+	if *upperFlag {
+		if err := checkUppercase(alphabet); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+	}
+	// Alphabet warnings become errors under -strict
+	warn := func(format string, a ...interface{}) {
+		if *strictFlag {
+			fmt.Fprintf(os.Stderr, "Error: "+format+"\n", a...)
+			os.Exit(1)
+		}
+		fmt.Fprintf(os.Stderr, "Warning: "+format+"\n", a...)
+	}
+	if symbols := bidiSymbols(alphabet); len(symbols) > 0 {
+		warn("alphabet contains right-to-left or format characters: %s", strings.Join(symbols, " "))
+	}
+	if *foldFlag {
+		if pairs := caseCollisions(alphabet); len(pairs) > 0 {
+			warn("alphabet symbols differ only in case: %s", strings.Join(pairs, " "))
+		}
+	}
+
+	if *spbFlag < 2 || *spbFlag > maxSymbolsPerByte {
+		fmt.Fprintf(os.Stderr, "Error: -spb must be between 2 and %d\n", maxSymbolsPerByte)
+		os.Exit(1)
+	}
+
+	if *selfTestFlag {
+		if err := selfTest(encodeMap, decodeMap, *spbFlag); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: self-test failed: %v\n", err)
+			os.Exit(1)
+		}
+		// Without input files and with stdin on a terminal there is
+		// nothing else to do.
+		if flag.NArg() == 0 && isTerminal(os.Stdin) {
+			if !*quietFlag {
+				fmt.Fprintf(os.Stderr, "Self-test passed\n")
+			}
+			os.Exit(0)
+		}
+	}
+
+	if flagGiven("encode-int") || flagGiven("decode-int") {
+		var result string
+		if flagGiven("encode-int") {
+			result, err = encodeInt(*encIntFlag, encodeMap, *spbFlag)
+		} else {
+			result, err = decodeInt(*decIntFlag, decodeMap, *spbFlag)
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Println(result)
+		return
+	}
+
+	for _, r := range *prefixFlag {
+		if _, ok := decodeMap[r]; !ok {
+			fmt.Fprintf(os.Stderr, "Error: -prefix symbol %q is not in the alphabet\n", r)
+			os.Exit(1)
+		}
+	}
+
+	encOpts := encodeOptions{width: *widthFlag, eol: "\r\n", finalEOL: *finalEOLFlag, spb: *spbFlag, prefix: *prefixFlag, oddMap: oddEncode}
+	decOpts := decodeOptions{overflow: *overflowFlag, spb: *spbFlag, lengthPrefix: *lengthFlag, prefix: *prefixFlag, maxLine: *maxLineFlag, oddMap: oddDecode}
+	if *byteSepFlag != "" {
+		if encOpts.groupDelimiter, err = parseSeparator(*byteSepFlag, decodeMap); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: invalid -byte-delimiter: %v\n", err)
+			os.Exit(1)
+		}
+		encOpts.groupSize = *spbFlag
+	}
+	if *speakFlag < 0 {
+		fmt.Fprintf(os.Stderr, "Error: -pronounceable must not be negative\n")
+		os.Exit(1)
+	}
+	if *speakFlag > 0 {
+		if _, ok := decodeMap[pronounceableDelimiter]; ok {
+			fmt.Fprintf(os.Stderr, "Error: -pronounceable delimiter %q is an alphabet symbol\n", pronounceableDelimiter)
+			os.Exit(1)
+		}
+		if pairs := foldCollisions(alphabet); len(pairs) > 0 {
+			fmt.Fprintf(os.Stderr, "Error: -pronounceable cannot upper-case alphabet symbols: %s\n", strings.Join(pairs, " "))
+			os.Exit(1)
+		}
+		encOpts.groupDelimiter, encOpts.groupSize = string(pronounceableDelimiter), *speakFlag
+		for digit, symbol := range encodeMap {
+			encodeMap[digit] = unicode.ToUpper(symbol)
+		}
+		decOpts.ignore, decOpts.fold = []byte(string(pronounceableDelimiter)), true
+	}
+	if *ignoreFlag != "" {
+		ignore, err := parseSeparator(*ignoreFlag, decodeMap)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: invalid -ignore: %v\n", err)
+			os.Exit(1)
+		}
+		decOpts.ignore = []byte(ignore)
+	}
+	if *replaceFlag != "" {
+		b, err := strconv.ParseUint(*replaceFlag, 0, 8)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: invalid -replace-unknown byte %q\n", *replaceFlag)
+			os.Exit(1)
+		}
+		decOpts.replaced = new(atomic.Int64)
+		decOpts.replacement = byte(b)
+	}
+	if *normFlag {
+		if pairs := foldCollisions(alphabet); len(pairs) > 0 {
+			fmt.Fprintf(os.Stderr, "Error: -fold would merge alphabet symbols: %s\n", strings.Join(pairs, " "))
+			os.Exit(1)
+		}
+		decOpts.fold = true
+	}
+	if *checkFlag < 0 {
+		fmt.Fprintf(os.Stderr, "Error: -checkpoint must not be negative\n")
+		os.Exit(1)
+	}
+	if *checkFlag > 0 {
+		if _, ok := decodeMap[checkpointMarker]; ok {
+			fmt.Fprintf(os.Stderr, "Error: -checkpoint marker %q is an alphabet symbol\n", checkpointMarker)
+			os.Exit(1)
+		}
+		encOpts.checkpoint = *checkFlag
+	}
+	if *padFlag {
+		if _, ok := decodeMap[padRune]; ok {
+			fmt.Fprintf(os.Stderr, "Error: -pad-final rune %q is an alphabet symbol\n", padRune)
+			os.Exit(1)
+		}
+		encOpts.padFinal = true
+	}
+	if *blockFlag < 0 {
+		fmt.Fprintf(os.Stderr, "Error: -block must not be negative\n")
+		os.Exit(1)
+	}
+	if *blockFlag > 0 {
+		if _, ok := decodeMap[checkpointMarker]; ok {
+			fmt.Fprintf(os.Stderr, "Error: -block marker %q is an alphabet symbol\n", checkpointMarker)
+			os.Exit(1)
+		}
+		encOpts.block = *blockFlag
+	}
+	if *rulerFlag && strings.ContainsAny(alphabet, rulerRunes) {
+		fmt.Fprintf(os.Stderr, "Error: -ruler needs an alphabet without any of %q\n", rulerRunes)
 		os.Exit(1)
 	}
+	if *numbersFlag {
+		if strings.ContainsAny(alphabet, ": ") {
+			fmt.Fprintf(os.Stderr, "Error: -line-numbers needs an alphabet without ':' and space\n")
+			os.Exit(1)
+		}
+		encOpts.lineNumbers = true
+		decOpts.lineNumbers = true
+	}
+	if *eccFlag && !flagGiven("on-overflow") {
+		// A mistyped symbol may yield a value above 255; keep it as a
+		// damaged byte for the check bytes to repair
+		decOpts.overflow = "wrap"
+	}
+	if *eolRawFlag != "" {
+		if encOpts.eol, err = parseSeparator(*eolRawFlag, decodeMap); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: invalid -eol-raw: %v\n", err)
+			os.Exit(1)
+		}
+		decOpts.sep = []byte(encOpts.eol)
+	}
+	if *aliasFlag != "" {
+		if decOpts.aliases, err = parseAliases(*aliasFlag, decodeMap); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: invalid -alias: %v\n", err)
+			os.Exit(1)
+		}
+	}
+	if *delimFlag != "" {
+		delim, err := parseSeparator(*delimFlag, decodeMap)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: invalid -message-delimiter: %v\n", err)
+			os.Exit(1)
+		}
+		decOpts.delim = []byte(delim)
+		if err := checkTemplate(*templateFlag); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: invalid -out-template: %v\n", err)
+			os.Exit(1)
+		}
+	}
+
+	// Custom settings are checked on every run, the defaults are known good
+	if *checkCfgFlag || alphabet != defaultAlphabet || *spbFlag != 2 || decOpts.fold {
+		if err := checkRoundTrip(encodeMap, decodeMap, encOpts, decOpts); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: configuration does not round-trip: %v\n", err)
+			os.Exit(1)
+		}
+		if *checkCfgFlag {
+			if !*quietFlag {
+				fmt.Fprintf(os.Stderr, "Configuration OK: all 256 byte values round-trip\n")
+			}
+			return
+		}
+	}
+
+	if *explainFlag {
+		fmt.Print(explainPlan(alphabet, encOpts, decOpts))
+		return
+	}
+	if *countFlag || *linesCountFl {
+		var size int64
+		if *sizeFlag != "" {
+			size, err = parseSize(*sizeFlag)
+		} else {
+			size, err = inputSize(flag.Args())
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		codec := codecInput(size, *eccFlag, *lengthFlag)
+		if *qrFlag > 0 && codec < int64(*qrFlag) {
+			fmt.Fprintf(os.Stderr, "Error: %d bytes cannot fill %d segments\n", codec, *qrFlag)
+			os.Exit(1)
+		}
+		if *linesCountFl {
+			// The -ruler and -digest-line comments take a line each
+			lines := encodedLines(codec, encOpts, *qrFlag)
+			if *rulerFlag {
+				lines++
+			}
+			if *digestFlag != "" {
+				lines++
+			}
+			fmt.Println(lines)
+			return
+		}
+		fmt.Print(transcriptionEstimate(size, codec, encOpts, *qrFlag, *spmFlag))
+		return
+	}
+
+	// The encode index and the decode offset map share their settings
+	var index *bufio.Writer
+	var indexFile *os.File
+	var resumeIndex []indexEntry
+	var resumeIndexStart int64
+	indexName, indexPath, indexHeader := "index", *indexFlag, "input_offset,output_offset"
+	if *decodeFlag {
+		indexName, indexPath, indexHeader = "offset-map", *offsetFlag, "encoded_offset,encoded_rune,decoded_offset"
+	}
+	if indexPath != "" {
+		if *intervalFlag <= 0 {
+			fmt.Fprintf(os.Stderr, "Error: -index-interval must be positive\n")
+			os.Exit(1)
+		}
+		if flag.NArg() > 1 {
+			fmt.Fprintf(os.Stderr, "Error: -%s needs a single input\n", indexName)
+			os.Exit(1)
+		}
+	}
+
+	// -entropy describes the input as given, so it counts the bytes
+	// before -length-prefix, -dedup-block, -dict, -pipeline or -ecc change
+	// them; -symbol-stats counts what the codec encodes
+	var inputHistogram *byteHistogram
+	if *entropyFlag {
+		inputHistogram = new(byteHistogram)
+	}
+	if *statsFlag != "" {
+		encOpts.histogram = new([256]int64)
+		if oddEncode != nil {
+			encOpts.oddHistogram = new([256]int64)
+		}
+	}
+
+	var dict [][]byte
+	if *dictFlag != "" {
+		if dict, err = loadDict(*dictFlag); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: invalid -dict: %v\n", err)
+			os.Exit(1)
+		}
+	}
 
-	if err := writer.Flush(); err != nil {
-		fmt.Fprintf(os.Stderr, "\nError flushing output: %v\n", err)
+	var dedup *dedupTable
+	if *dedupFlag < 0 {
+		fmt.Fprintf(os.Stderr, "Error: -dedup-block must not be negative\n")
 		os.Exit(1)
 	}
+	if *dedupFlag > 0 {
+		dedup = newDedupTable(*dedupFlag)
+	}
 
-	fmt.Fprintf(os.Stderr, "\nOperation completed in %v\n", duration)
+	var stages []pipelineStage
+	if *pipelineFlag != "" {
+		if stages, err = parsePipeline(*pipelineFlag); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: invalid -pipeline: %v\n", err)
+			os.Exit(1)
+		}
+	}
+
+	// recordBytes counts the input for -strict-length when encoding;
+	// records counts the output when decoding.
+	var recordBytes int64
+	var records *countingWriter
+
+	// digest hashes the input for -digest-line when encoding and the
+	// output when decoding; foundDigest is the digest read back.
+	var digest hash.Hash
+	var foundDigest string
+	if *digestFlag != "" {
+		if digest, err = newDigest(*digestFlag); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: invalid -digest-line: %v\n", err)
+			os.Exit(1)
+		}
+		if _, ok := decodeMap[commentMarker]; ok {
+			fmt.Fprintf(os.Stderr, "Error: -digest-line marker %q is an alphabet symbol\n", commentMarker)
+			os.Exit(1)
+		}
+		decOpts.digest = &foundDigest
+	}
+
+	openInput := func(r io.Reader) (*bufio.Reader, error) {
+		if *budgetFlag > 0 {
+			r = newBudgetReader(r)
+		}
+		if *fromHexFlag && !*decodeFlag {
+			r = hex.NewDecoder(spaceSkipper{r})
+		}
+		if *fromB64Flag && !*decodeFlag {
+			r = base64.NewDecoder(base64.StdEncoding, spaceSkipper{r})
+		}
+		if *preCmdFlag != "" && !*decodeFlag {
+			filter, err := startFilter(*preCmdFlag, r)
+			if err != nil {
+				return nil, fmt.Errorf("cannot run -pre-cmd command: %w", err)
+			}
+			r = filter
+		}
+		if *recordFlag > 0 && !*decodeFlag {
+			r = countingReader{r: r, n: &recordBytes}
+		}
+		if digest != nil && !*decodeFlag {
+			r = io.TeeReader(r, digest)
+		}
+		if inputHistogram != nil {
+			r = io.TeeReader(r, inputHistogram)
+		}
+		if dedup != nil && !*decodeFlag {
+			r = newDedupReader(r, dedup)
+		}
+		if dict != nil && !*decodeFlag {
+			r = newDictReader(r, dict)
+		}
+		if !*decodeFlag {
+			r = pipelineReader(r, stages)
+		}
+		if *eccFlag && !*decodeFlag {
+			r = &eccReader{r: r}
+		}
+		return bufio.NewReaderSize(r, bufferSize), nil
+	}
+
+	// The codec writes to chain.top; output transforms are stacked between
+	// it and the buffer in front of the output. The output is only opened
+	// once every check has passed, so a rejected command line leaves an
+	// existing file alone.
+	var outFile *os.File
+	var rotation *rotatingFile
+	var command *commandSink
+	var messages *messageFiles
+	chain := newOutputChain(nil)
+	if *goFlag != "" {
+		if *decodeFlag {
+			literal := &goBytesWriter{w: chain.top, name: *goFlag}
+			chain.push(literal, literal.Close)
+		} else {
+			literal := &goStringWriter{w: chain.top, name: *goFlag}
+			chain.push(literal, literal.Close)
+		}
+	}
+	if *toHexFlag && *decodeFlag {
+		chain.push(hex.NewEncoder(chain.top), nil)
+	}
+	if (*decimalFlag || *hexValFlag) && *decodeFlag {
+		values := &byteValueWriter{w: chain.top, format: "%d"}
+		if *hexValFlag {
+			values.format = "%02x"
+		}
+		chain.push(values, values.Close)
+	}
+	if *toB64Flag && *decodeFlag {
+		b64 := base64.NewEncoder(base64.StdEncoding, chain.top)
+		chain.push(b64, b64.Close)
+	}
+	if *jsonFlag && *decodeFlag {
+		envelope := &jsonEnvelope{w: chain.top}
+		chain.push(envelope, envelope.Close)
+	}
+	if (*startFlag > 0 || *windowFlag >= 0) && *decodeFlag {
+		chain.push(&windowWriter{w: chain.top, skip: *startFlag, remain: *windowFlag}, nil)
+	}
+	var postCmd *commandSink
+	if *postCmdFlag != "" && *decodeFlag {
+		if postCmd, err = startCommand(*postCmdFlag, chain.top); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: cannot run -post-cmd command: %v\n", err)
+			os.Exit(1)
+		}
+		chain.push(postCmd, postCmd.Close)
+	}
+	if *recordFlag > 0 && *decodeFlag {
+		records = &countingWriter{w: chain.top}
+		chain.push(records, nil)
+	}
+	if *expectFlag != "" && *decodeFlag {
+		checker := &utf8Checker{w: chain.top}
+		chain.push(checker, checker.Close)
+	}
+	var trimmer *zeroTrimmer
+	if *trimFlag && *decodeFlag {
+		trimmer = &zeroTrimmer{w: chain.top}
+		chain.push(trimmer, nil)
+	}
+	// The digest covers the decoded bytes before -trim-trailing-zeros
+	// drops any, like the input it was computed from
+	if digest != nil && *decodeFlag {
+		chain.push(io.MultiWriter(chain.top, digest), nil)
+	}
+	if dedup != nil && *decodeFlag {
+		expand := &dedupWriter{w: chain.top, size: *dedupFlag}
+		chain.push(expand, expand.Close)
+	}
+	if dict != nil && *decodeFlag {
+		expand := &dictWriter{w: chain.top, dict: dict}
+		chain.push(expand, expand.Close)
+	}
+	if *decodeFlag {
+		pushPipeline(chain, stages)
+	}
+	if *autoGzipFlag && *decodeFlag {
+		gunzip := &gzipSniffer{w: chain.top}
+		chain.push(gunzip, gunzip.Close)
+	}
+	var ecc *eccWriter
+	if *eccFlag && *decodeFlag {
+		ecc = newECCWriter(chain.top)
+		chain.push(ecc, ecc.Close)
+	}
+	var preview *previewWriter
+	if *previewFlag && !*quietFlag && !*decodeFlag {
+		preview = &previewWriter{limit: previewSymbols}
+		chain.push(io.MultiWriter(chain.top, preview), nil)
+	}
+	var tail *tailWriter
+	if digest != nil && !*decodeFlag {
+		tail = &tailWriter{w: chain.top, keep: len(encOpts.eol)}
+		chain.push(tail, nil)
+	}
+	writer := chain.top
+	encOpts.flush = func() error {
+		if err := chain.flush(); err != nil {
+			return err
+		}
+		if outFile != nil {
+			if err := outFile.Sync(); err != nil {
+				return err
+			}
+		}
+		// The index follows the output, so its entries never point past
+		// what a -resume finds on disk
+		if index != nil {
+			if err := index.Flush(); err != nil {
+				return err
+			}
+			return indexFile.Sync()
+		}
+		return nil
+	}
+
+	if *memoryFlag != "" {
+		limit, _ := parseSize(*memoryFlag) // checked by validateFlags
+		lineRunes := unwrappedChunk
+		if *widthFlag > 0 {
+			lineRunes = *widthFlag + *spbFlag
+		}
+		// One input buffer, every output layer and the index buffer
+		buffers := 1 + len(chain.layers)
+		if indexPath != "" {
+			buffers++
+		}
+		if *idleFlag > 0 {
+			// The read buffer of readBursts
+			buffers++
+		}
+		if *continueFlag {
+			// The writer holding back the output of each file
+			buffers++
+		}
+		if err := setMemoryLimit(limit, buffers, lineRunes); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+	}
+
+	if indexPath != "" {
+		if *resumeFlag {
+			// Keep the entries; -resume continues from the last one
+			indexFile, err = os.OpenFile(indexPath, os.O_RDWR|os.O_CREATE, 0o644)
+			if err == nil {
+				resumeIndex, resumeIndexStart, err = readIndex(indexFile, indexHeader)
+			}
+		} else {
+			indexFile, err = os.Create(indexPath)
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		index = bufio.NewWriter(indexFile)
+		if resumeIndexStart == 0 {
+			fmt.Fprintln(index, indexHeader)
+		}
+		encOpts.index, decOpts.offsetMap = index, index
+		encOpts.indexInterval, decOpts.offsetInterval = *intervalFlag, *intervalFlag
+	}
+
+	var sink io.Writer = os.Stdout
+	var resumeFrom int64
+	if *outputFlag != "" {
+		mode := os.O_WRONLY | os.O_CREATE | os.O_TRUNC
+		if *appendFlag {
+			mode = os.O_WRONLY | os.O_CREATE | os.O_APPEND
+		}
+		if *resumeFlag {
+			mode = os.O_RDWR | os.O_CREATE
+		}
+		if outFile, err = os.OpenFile(*outputFlag, mode, 0o644); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		if *resumeFlag {
+			var keep int64
+			if index != nil {
+				var cut int64
+				keep, resumeFrom, encOpts.column, cut, err = indexResumePoint(outFile, resumeIndex, resumeIndexStart, decodeMap, encOpts)
+				if err == nil && resumeIndexStart > 0 {
+					err = indexFile.Truncate(cut)
+				}
+				if err == nil {
+					_, err = indexFile.Seek(cut, io.SeekStart)
+				}
+				encOpts.resumedInput, encOpts.resumedOutput = resumeFrom, keep
+			} else {
+				keep, resumeFrom, err = resumePoint(outFile, decodeMap, encOpts)
+			}
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Error: cannot resume %s: %v\n", *outputFlag, err)
+				os.Exit(1)
+			}
+			if err := outFile.Truncate(keep); err != nil {
+				fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+				os.Exit(1)
+			}
+			if _, err := outFile.Seek(keep, io.SeekStart); err != nil {
+				fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+				os.Exit(1)
+			}
+			if !*quietFlag {
+				fmt.Fprintf(os.Stderr, "Resuming after %d input bytes\n", resumeFrom)
+			}
+		}
+		sink = outFile
+	}
+	if *rotateFlag != "" {
+		interval, _ := parseRotate(*rotateFlag) // checked by validateFlags
+		if rotation, err = newRotatingFile(*rotNameFlag, interval); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		sink = rotation
+	}
+	if *execFlag != "" {
+		if command, err = startCommand(*execFlag, os.Stdout); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: cannot run -exec command: %v\n", err)
+			os.Exit(1)
+		}
+		sink = command
+	}
+	if sink == os.Stdout && *decodeFlag && decOpts.delim == nil && !*toHexFlag && !*toB64Flag && *goFlag == "" && !*decimalFlag && !*hexValFlag && !*jsonFlag && !*inspectFlag && !*forceFlag && isTerminal(os.Stdout) {
+		fmt.Fprintf(os.Stderr, "Error: refusing to write decoded binary data to a terminal; redirect the output, use -o or -to-hex, or pass -force\n")
+		os.Exit(1)
+	}
+	if decOpts.delim != nil && *decodeFlag {
+		messages = &messageFiles{template: *templateFlag}
+		sink = messages
+	}
+	chain.attach(sink)
+	if rotation != nil {
+		encOpts.rotateDue = &rotation.due
+		encOpts.rotate = func() error {
+			if err := chain.flush(); err != nil {
+				return err
+			}
+			return rotation.rotate()
+		}
+	}
+
+	if messages != nil {
+		decOpts.endMessage = func() error {
+			if err := chain.flush(); err != nil {
+				return err
+			}
+			if trimmer != nil {
+				trimmer.drop()
+			}
+			return messages.end()
+		}
+	}
+
+	var qr *qrSegments
+	if *qrFlag > 0 && *decodeFlag {
+		qr = newQRSegments(*qrFlag)
+	}
+
+	run := func(reader *bufio.Reader) error {
+		// The -prefix starts the output, not every input
+		defer func() { encOpts.prefix = "" }()
+		// Encoding with -reverse reads the input itself, see encodeWhole
+		if *bufferedFlag || *reverseFlag && *decodeFlag {
+			data, err := readAll(reader)
+			if err != nil {
+				return err
+			}
+			reader = bufio.NewReader(bytes.NewReader(data))
+		}
+		if *inferFlag {
+			data, err := readAll(reader)
+			if err != nil {
+				return err
+			}
+			name, err := inferPreset(data)
+			if err != nil {
+				return err
+			}
+			if _, decodeMap, err = createMaps(presets[name].alphabet); err != nil {
+				return err
+			}
+			if !*quietFlag {
+				fmt.Fprintf(os.Stderr, "Inferred preset %s\n", name)
+			}
+			reader = bufio.NewReader(bytes.NewReader(data))
+		}
+		if *decodeFlag && *parallelFlag {
+			data, err := readAll(reader)
+			if err != nil {
+				return err
+			}
+			return decodeParallel(data, writer, decodeMap, decOpts)
+		}
+		if qr != nil {
+			data, err := readAll(reader)
+			if err != nil {
+				return err
+			}
+			return qr.add(data)
+		}
+		if *decodeFlag && *inspectFlag {
+			return inspectInput(reader, writer, decodeMap, decOpts)
+		}
+		if *decodeFlag && *reverseFlag {
+			return decodeReversed(reader, writer, decodeMap, decOpts)
+		}
+		if *decodeFlag {
+			return decode(reader, writer, decodeMap, decOpts)
+		}
+		if *rewrapFlag {
+			return rewrap(reader, writer, decodeMap, decOpts.sep, encOpts)
+		}
+		if *autoGzipFlag {
+			if magic, _ := reader.Peek(2); isGzip(magic) && !*quietFlag {
+				fmt.Fprintf(os.Stderr, "Note: input is already gzip-compressed\n")
+			}
+		}
+		if *lengthFlag {
+			var err error
+			if reader, err = withLengthPrefix(reader); err != nil {
+				return err
+			}
+		}
+		if *linesFlag > 0 {
+			return encodeLines(reader, writer, encodeMap, *linesFlag, encOpts)
+		}
+		if *qrFlag > 0 {
+			return encodeSegments(reader, writer, encodeMap, decodeMap, *qrFlag, encOpts)
+		}
+		if *reverseFlag {
+			return encodeReversed(reader, writer, encodeMap, decodeMap, encOpts)
+		}
+		return encode(reader, writer, encodeMap, encOpts)
+	}
+
+	start := now()
+	if *budgetFlag > 0 {
+		startBudget(*budgetFlag)
+	}
+	if *rulerFlag {
+		if _, err := writer.WriteString(ruler(encOpts.width) + encOpts.eol); err != nil {
+			printError("Error writing output: %v", err)
+			os.Exit(1)
+		}
+	}
+	failed := 0
+	if *urlFlag != "" {
+		body, err := openURL(*urlFlag, *budgetFlag)
+		if err == nil {
+			var reader *bufio.Reader
+			if reader, err = openInput(body); err == nil {
+				err = run(reader)
+			}
+			body.Close()
+		}
+		if err != nil {
+			printError("Error: %v", err)
+			os.Exit(commandExitCode(err))
+		}
+	} else if *idleFlag > 0 {
+		// Every record is a run of its own, so -index entries count from
+		// the start of the record. A record cut short by the time budget
+		// reports it; otherwise the budget ran out during a pause and is
+		// reported here.
+		recorded, reported := 0, false
+		err := readBursts(os.Stdin, *idleFlag, func(data []byte) error {
+			if budgetExpired.Load() {
+				return nil
+			}
+			reader, err := openInput(bytes.NewReader(data))
+			if err != nil {
+				return err
+			}
+			if err := run(reader); err != nil {
+				return err
+			}
+			if budgetExpired.Load() {
+				reported = true
+			} else {
+				recorded += len(data)
+			}
+			if _, err := writer.WriteString(encOpts.eol); err != nil {
+				return err
+			}
+			return encOpts.flush()
+		})
+		if err != nil {
+			printError("Error: %v", err)
+			os.Exit(commandExitCode(err))
+		}
+		if !reported {
+			reportBudget(recorded)
+		}
+	} else if flag.NArg() == 0 {
+		startProgress(os.Stdin)
+		err := skipInput(os.Stdin, resumeFrom)
+		var reader *bufio.Reader
+		if err == nil {
+			reader, err = openInput(os.Stdin)
+		}
+		if err == nil {
+			err = run(reader)
+		}
+		if err != nil {
+			printError("Error: %v", err)
+			os.Exit(commandExitCode(err))
+		}
+	}
+	// With -continue-on-error, the output of each file is held back until
+	// the file is done, so that a failed file adds nothing to the output
+	var held heldOutput
+	for _, name := range flag.Args() {
+		if budgetExpired.Load() {
+			break
+		}
+		if *continueFlag {
+			writer = bufio.NewWriterSize(&held, bufferSize)
+		}
+		err := runFile(name, func(f *os.File) error {
+			if *perFileFlag {
+				fileMap, fileAlphabet, err := sidecarAlphabet(name)
+				if err != nil {
+					return err
+				}
+				decodeMap = fileMap
+				if !*quietFlag {
+					fmt.Fprintf(os.Stderr, "%s: alphabet %s\n", name, fileAlphabet)
+				}
+			}
+			startProgress(f)
+			if err := skipInput(f, resumeFrom); err != nil {
+				return err
+			}
+			reader, err := openInput(f)
+			if err != nil {
+				return err
+			}
+			return run(reader)
+		})
+		if *continueFlag {
+			if err == nil {
+				err = writer.Flush()
+			}
+			writer = chain.top
+			if err == nil {
+				if _, err = writer.Write(held.Bytes()); err != nil {
+					printError("Error writing output: %v", err)
+					os.Exit(1)
+				}
+			}
+			held.Reset()
+		}
+		if err == nil {
+			continue
+		}
+		printError("Error: %s: %v", name, err)
+		if !*continueFlag {
+			os.Exit(commandExitCode(err))
+		}
+		failed++
+	}
+	if qr != nil {
+		data, err := qr.joined()
+		if err == nil {
+			err = decode(bufio.NewReader(bytes.NewReader(data)), writer, decodeMap, decOpts)
+		}
+		if err != nil {
+			printError("Error: %v", err)
+			os.Exit(1)
+		}
+	}
+	if digest != nil && !*decodeFlag {
+		line := digestLine(digest) + encOpts.eol
+		err := writer.Flush()
+		if err == nil && tail.needsBreak(encOpts.eol) {
+			line = encOpts.eol + line
+		}
+		if err == nil {
+			_, err = writer.WriteString(line)
+		}
+		if err != nil {
+			printError("Error writing output: %v", err)
+			os.Exit(1)
+		}
+	}
+	duration := now().Sub(start)
+
+	if err := chain.close(); err != nil {
+		printError("Error: %v", err)
+		os.Exit(commandExitCode(err))
+	}
+	if digest != nil && *decodeFlag && !budgetExpired.Load() {
+		if err := checkDigest(foundDigest, digest); err != nil {
+			printError("Error: %v", err)
+			os.Exit(1)
+		}
+	}
+	if outFile != nil {
+		if err := outFile.Close(); err != nil {
+			printError("Error writing output: %v", err)
+			os.Exit(1)
+		}
+	}
+	if command != nil {
+		if err := command.Close(); err != nil {
+			printError("Error: -exec command %v", err)
+			os.Exit(commandExitCode(err))
+		}
+	}
+	if rotation != nil {
+		if err := rotation.Close(); err != nil {
+			printError("Error writing output: %v", err)
+			os.Exit(1)
+		}
+		if !*quietFlag {
+			fmt.Fprintf(os.Stderr, "Wrote %d files: %s\n", len(rotation.names), strings.Join(rotation.names, ", "))
+		}
+		if *manifestFlag != "" {
+			if err := writeManifest(*manifestFlag, rotation.names, decodeMap, decOpts); err != nil {
+				printError("Error writing manifest: %v", err)
+				os.Exit(1)
+			}
+		}
+	}
+	if preview != nil {
+		preview.show()
+	}
+	if messages != nil {
+		if err := messages.close(); err != nil {
+			printError("Error: %v", err)
+			os.Exit(1)
+		}
+		if !*quietFlag {
+			fmt.Fprintf(os.Stderr, "Wrote %d message files\n", messages.count)
+		}
+	}
+	if index != nil {
+		if err := index.Flush(); err != nil {
+			printError("Error writing index: %v", err)
+			os.Exit(1)
+		}
+		if err := indexFile.Close(); err != nil {
+			printError("Error writing index: %v", err)
+			os.Exit(1)
+		}
+	}
+
+	if ecc != nil && ecc.corrected > 0 && !*quietFlag {
+		fmt.Fprintf(os.Stderr, "Corrected %d damaged blocks\n", ecc.corrected)
+	}
+	if decOpts.replaced != nil && decOpts.replaced.Load() > 0 {
+		fmt.Fprintf(os.Stderr, "Replaced %d invalid groups\n", decOpts.replaced.Load())
+	}
+	if *statsFlag != "" {
+		if err := writeSymbolStats(*statsFlag, symbolCounts(encOpts.histogram, encOpts.oddHistogram, encodeMap, encOpts.oddMap, encOpts.spb)); err != nil {
+			printError("Error writing symbol stats: %v", err)
+			os.Exit(1)
+		}
+	}
+	if *entropyFlag && !*quietFlag {
+		fmt.Fprintf(os.Stderr, "Entropy: %.3f bits/byte\n", entropy((*[256]int64)(inputHistogram)))
+	}
+
+	if *recordFlag > 0 && !budgetExpired.Load() {
+		what, n := "input is", recordBytes
+		if records != nil {
+			what, n = "output is", records.n
+		}
+		if err := checkRecordLength(what, n, *recordFlag); err != nil {
+			printError("Error: %v", err)
+			os.Exit(1)
+		}
+	}
+
+	if failed > 0 {
+		fmt.Fprintf(os.Stderr, "\n%d of %d files failed\n", failed, flag.NArg())
+		os.Exit(1)
+	}
+
+	if !*quietFlag {
+		fmt.Fprintf(os.Stderr, "\n%s\n", completionSummary(duration))
+	}
 }
 
-func createMaps() (map[byte]rune, map[rune]byte) {
+// runFile opens the named input file, hands it to fn and closes it again.
+func runFile(name string, fn func(*os.File) error) error {
+	f, err := os.Open(name)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return fn(f)
+}
+
+// heldOutput collects the output of one input file for
+// -continue-on-error, failing once it exceeds wholeInputLimit.
+type heldOutput struct {
+	bytes.Buffer
+}
+
+func (h *heldOutput) Write(p []byte) (int, error) {
+	if wholeInputLimit > 0 && int64(h.Len()+len(p)) > wholeInputLimit {
+		return 0, fmt.Errorf("output of one input exceeds the %d bytes left by -max-memory", wholeInputLimit)
+	}
+	return h.Buffer.Write(p)
+}
+
+func createMaps(alphabet string) (map[byte]rune, map[rune]byte, error) {
 	encodeMap := make(map[byte]rune)
 	decodeMap := make(map[rune]byte)
 
-	// Code30: A-Z, ÄÖÜẞ (30 characters)
-	code30 := []rune("ABCDEFGHIJKLMNOPQRSTUVWXYZÄÖÜẞ")
+	code30 := []rune(alphabet)
+	if len(code30) != 30 {
+		return nil, nil, fmt.Errorf("need 30 symbols, got %d", len(code30))
+	}
 
 	for i := 0; i < 30; i++ {
+		if code30[i] == '\r' || code30[i] == '\n' {
+			return nil, nil, fmt.Errorf("line break cannot be a symbol")
+		}
+		if _, dup := decodeMap[code30[i]]; dup {
+			return nil, nil, fmt.Errorf("duplicate symbol %q", code30[i])
+		}
 		encodeMap[byte(i)] = code30[i]
 		decodeMap[code30[i]] = byte(i)
 	}
 
-	return encodeMap, decodeMap
+	return encodeMap, decodeMap, nil
 }
 
-func encode(reader *bufio.Reader, writer *bufio.Writer, encodeMap map[byte]rune, width int) error {
+// maxSymbolsPerByte bounds -spb; 30^8 still fits comfortably in an int.
+const maxSymbolsPerByte = 8
+
+// encodeOptions collects the settings that shape encoding.
+type encodeOptions struct {
+	width int    // symbols per line, 0 for no wrapping
+	eol   string // written after every full line
+	spb   int    // symbols per byte
+
+	// finalEOL also terminates a partial last line with eol.
+	finalEOL bool
+
+	// padFinal fills a partial last line up to width with padRune.
+	padFinal bool
+
+	// oddMap, if set, encodes the bytes at odd indexes of the input
+	// instead of the encode map, for -alt-alphabets.
+	oddMap map[byte]rune
+
+	// prefix is written verbatim before the data, outside line wrapping.
+	prefix string
+
+	// index, if set, receives an "input,output" offset line every
+	// indexInterval input bytes and once more at the end of the input.
+	index         io.Writer
+	indexInterval int
+
+	// resumedInput and resumedOutput are added to the -index offsets of
+	// a resumed run; column is the number of symbols already on the line
+	// it continues.
+	resumedInput, resumedOutput int64
+	column                      int
+
+	// histogram, if set, counts the byte values encoded, for
+	// -symbol-stats; oddHistogram those at odd indexes, for -alt-alphabets.
+	histogram    *[256]int64
+	oddHistogram *[256]int64
+
+	// lineNumbers starts every wrapped line with its number, see
+	// lineNumber.
+	lineNumbers bool
+
+	// checkpoint, if set, writes checkpointMarker after every checkpoint
+	// input bytes and calls flush, so that the output up to there is a
+	// complete, decodable prefix.
+	checkpoint int
+	flush      func() error
+
+	// block, if set, writes checkpointMarker after every block input
+	// bytes without flushing, so -parallel-decode can cut unwrapped
+	// output there.
+	block int
+
+	// groupDelimiter is written between groups of groupSize symbols
+	// within a line.
+	groupDelimiter string
+	groupSize      int
+
+	// rotate is called after the next complete symbol group once
+	// rotateDue is set, to switch to a new output file.
+	rotateDue *atomic.Bool
+	rotate    func() error
+}
+
+// pronounceableDelimiter joins the blocks of -pronounceable.
+const pronounceableDelimiter = '-'
+
+// padRune fills up the last line with -pad-final. It must not be an
+// alphabet symbol; decode skips it wherever it is not one.
+const padRune = '='
+
+// checkpointMarker is written at checkpoints. It must not be an alphabet
+// symbol; decode skips it wherever it is not one.
+const checkpointMarker = '~'
+
+// lineNumber returns the "NNN: " prefix of the given line, or "" when
+// lines are not numbered. Decode with -line-numbers strips it again.
+func (opts encodeOptions) lineNumber(line int) string {
+	if !opts.lineNumbers {
+		return ""
+	}
+	return fmt.Sprintf("%03d: ", line)
+}
+
+// unwrappedChunk is the number of symbols encode collects before writing
+// them out when no line width is set.
+const unwrappedChunk = 64 * 1024
+
+// encode writes every input byte as opts.spb base-30 digits, least
+// significant digit first. Leading zero digits are always written, so
+// every byte takes exactly opts.spb symbols whatever its value; decode,
+// -resume and -offset-map rely on this fixed width.
+func encode(reader *bufio.Reader, writer *bufio.Writer, encodeMap map[byte]rune, opts encodeOptions) error {
 	totalBytes := 0
+	width := opts.width
 	lineBuffer := make([]rune, 0, width)
 	base := 30
 
-	for {
+	// Output bytes written so far and held in lineBuffer, for the index
+	written, pending := 0, 0
+	line := 1
+
+	// Symbols on the current line, including those already written out
+	// at checkpoints
+	lineSymbols := opts.column
+
+	// writeTail ends a partial last line
+	writeTail := func() error {
+		if len(lineBuffer) == 0 && (width == 0 || lineSymbols == 0) {
+			return nil
+		}
+		number := opts.lineNumber(line)
+		tail := number + string(lineBuffer)
+		pending += len(number)
+		if opts.padFinal && width > 0 && lineSymbols < width {
+			padding := strings.Repeat(string(padRune), width-lineSymbols)
+			tail += padding
+			pending += len(padding)
+		}
+		if width > 0 && opts.finalEOL {
+			tail += opts.eol
+			pending += len(opts.eol)
+		}
+		if _, err := writer.WriteString(tail); err != nil {
+			return fmt.Errorf("error writing final output: %w", err)
+		}
+		lineBuffer = lineBuffer[:0]
+		lineSymbols = 0
+		written += pending
+		pending = 0
+		return nil
+	}
+
+	if opts.prefix != "" {
+		if _, err := writer.WriteString(opts.prefix); err != nil {
+			return fmt.Errorf("error writing output: %w", err)
+		}
+		written += len(opts.prefix)
+	}
+
+	for !budgetExpired.Load() {
 		b, err := reader.ReadByte()
 		if err == io.EOF {
 			break
@@ -89,90 +1330,507 @@ func encode(reader *bufio.Reader, writer *bufio.Writer, encodeMap map[byte]rune,
 			return fmt.Errorf("error reading input: %w", err)
 		}
 
-		// Split the byte into two parts: division and remainder for mapping
-		div := b / byte(base) // First part
-		rem := b % byte(base) // Second part
+		if opts.histogram != nil {
+			opts.histogram[b]++
+			if opts.oddHistogram != nil && totalBytes%2 == 1 {
+				opts.oddHistogram[b]++
+			}
+		}
+
+		if opts.index != nil && totalBytes%opts.indexInterval == 0 {
+			if _, err := fmt.Fprintf(opts.index, "%d,%d\n", opts.resumedInput+int64(totalBytes), opts.resumedOutput+int64(written+pending)); err != nil {
+				return fmt.Errorf("error writing index: %w", err)
+			}
+		}
+
+		// Split the byte into digits: remainder first, then the division
+		for i := 0; i < opts.spb; i++ {
+			if opts.groupDelimiter != "" && lineSymbols+i > 0 && (lineSymbols+i)%opts.groupSize == 0 {
+				lineBuffer = append(lineBuffer, []rune(opts.groupDelimiter)...)
+				pending += len(opts.groupDelimiter)
+			}
+			digits := encodeMap
+			if opts.oddMap != nil && totalBytes%2 == 1 {
+				digits = opts.oddMap
+			}
+			symbol := digits[b%byte(base)]
+			lineBuffer = append(lineBuffer, symbol)
+			pending += utf8.RuneLen(symbol)
+			b /= byte(base)
+		}
+		lineSymbols += opts.spb
 
-		// Encode both parts using the map
-		lineBuffer = append(lineBuffer, encodeMap[rem], encodeMap[div])
+		if width > 0 && lineSymbols >= width {
+			number := opts.lineNumber(line)
+			if _, err := writer.WriteString(number + string(lineBuffer) + opts.eol); err != nil {
+				return fmt.Errorf("error writing output: %w", err)
+			}
+			lineBuffer = lineBuffer[:0]
+			written += len(number) + pending + len(opts.eol)
+			pending = 0
+			lineSymbols = 0
+			line++
+		}
 
-		if width > 0 && len(lineBuffer) >= width {
-			if _, err := writer.WriteString(string(lineBuffer) + "\r\n"); err != nil {
+		// Without wrapping, pass the symbols on in chunks rather than
+		// collecting the whole output
+		if width == 0 && len(lineBuffer) >= unwrappedChunk {
+			if _, err := writer.WriteString(string(lineBuffer)); err != nil {
 				return fmt.Errorf("error writing output: %w", err)
 			}
 			lineBuffer = lineBuffer[:0]
+			written += pending
+			pending = 0
 		}
 
 		totalBytes++
-		if totalBytes%bufferSize == 0 {
-			fmt.Fprintf(os.Stderr, "\rProcessed: %d MB", totalBytes/1024/1024)
+		reportProgress(totalBytes)
+
+		// Checkpoints and blocks count from the start of a resumed run
+		at := int(opts.resumedInput) + totalBytes
+		checkpoint := opts.checkpoint > 0 && at%opts.checkpoint == 0
+		if checkpoint || (opts.block > 0 && at%opts.block == 0) {
+			if _, err := writer.WriteString(string(lineBuffer) + string(checkpointMarker)); err != nil {
+				return fmt.Errorf("error writing output: %w", err)
+			}
+			lineBuffer = lineBuffer[:0]
+			written += pending + utf8.RuneLen(checkpointMarker)
+			pending = 0
+		}
+		if checkpoint {
+			if err := opts.flush(); err != nil {
+				return fmt.Errorf("error writing output: %w", err)
+			}
+		}
+
+		// Start the next file with a fresh line
+		if opts.rotateDue != nil && opts.rotateDue.Load() {
+			if err := writeTail(); err != nil {
+				return err
+			}
+			if err := opts.rotate(); err != nil {
+				return fmt.Errorf("error rotating output: %w", err)
+			}
+			line = 1
 		}
 	}
 
 	// Write any remaining data
-	if len(lineBuffer) > 0 {
-		if _, err := writer.WriteString(string(lineBuffer)); err != nil {
-			return fmt.Errorf("error writing final output: %w", err)
+	if err := writeTail(); err != nil {
+		return err
+	}
+
+	if opts.index != nil {
+		if _, err := fmt.Fprintf(opts.index, "%d,%d\n", opts.resumedInput+int64(totalBytes), opts.resumedOutput+int64(written+pending)); err != nil {
+			return fmt.Errorf("error writing index: %w", err)
 		}
 	}
 
-	fmt.Fprint(os.Stderr, "\n")
+	endProgress()
+	reportBudget(totalBytes)
 	return nil
 }
 
-func decode(reader *bufio.Reader, writer *bufio.Writer, decodeMap map[rune]byte) error {
+// decodeOptions collects the settings that shape decoding.
+type decodeOptions struct {
+	// overflow selects what happens to pairs above 255: "error" aborts,
+	// "wrap" keeps the low eight bits and "skip" drops the pair.
+	overflow string
+
+	spb int // symbols per byte
+
+	// sep is skipped wherever it occurs, like line breaks.
+	sep []byte
+
+	// delim separates messages; endMessage is called at each of them.
+	delim      []byte
+	endMessage func() error
+
+	// lengthPrefix reads the data length from the first bytes (see
+	// withLengthPrefix) and stops once that many bytes were decoded.
+	lengthPrefix bool
+
+	// quiet suppresses progress reporting, for segments decoded in parallel.
+	quiet bool
+
+	// prefix must precede the data; it is checked and dropped.
+	prefix string
+
+	// aliases are alternative spellings of symbols.
+	aliases []alias
+
+	// lineNumbers strips the "NNN: " prefixes written by encode.
+	lineNumbers bool
+
+	// ignore is skipped wherever it occurs, like sep.
+	ignore []byte
+
+	// fold accepts variants of the symbols, see foldSymbol.
+	fold bool
+
+	// offsetMap, if set, receives an "encoded byte,encoded rune,decoded"
+	// offset line every offsetInterval decoded bytes and once more at the
+	// end of the input.
+	offsetMap      io.Writer
+	offsetInterval int
+
+	// replaced, if set, turns groups with invalid characters into the
+	// replacement byte instead of failing, and counts them.
+	replaced    *atomic.Int64
+	replacement byte
+
+	// digest, if set, receives the digest of a -digest-line in the input.
+	digest *string
+
+	// maxLine, if positive, fails on lines longer than this many bytes.
+	// There is no limit by default: without -w encode writes the whole
+	// output as one line, so any finite default would reject valid input.
+	maxLine int
+
+	// oddMap, if set, holds the symbols of the bytes at odd indexes; the
+	// decode map has both alphabets, see altMaps.
+	oddMap map[rune]byte
+}
+
+// decode reverses encode. A symbol pair can describe values up to 899,
+// which do not fit a byte; see decodeOptions.overflow. Line breaks of any
+// kind and mixture (CRLF, LF, bare CR) and blank lines are skipped
+// wherever they occur, even between the two symbols of a pair.
+func decode(reader *bufio.Reader, writer *bufio.Writer, decodeMap map[rune]byte, opts decodeOptions) error {
 	totalBytes := 0
+	symbols := newSymbolReader(reader, decodeMap, opts)
 
-	for {
-		// Read two runes at a time to decode the original byte
-		remRune, _, err := reader.ReadRune()
-		if err == io.EOF {
-			break
-		}
-		if err != nil {
+	for _, want := range opts.prefix {
+		got, offset, err := symbols.next()
+		if err != nil && err != io.EOF && err != errMessageEnd {
 			return fmt.Errorf("error reading input: %w", err)
 		}
-
-		// Ignore line breaks or other unexpected characters
-		if remRune == '\r' || remRune == '\n' {
-			continue // Skip line breaks
+		if err != nil || got != want {
+			return fmt.Errorf("input does not start with prefix %q (mismatch at offset %d)", opts.prefix, offset)
 		}
+	}
+
+	group := make([]rune, opts.spb)
+	groups := 0 // symbol groups read, for the byte index of -alt-alphabets
+	base := 30
+
+	// Length prefix state: bytes of the prefix seen and the length it holds
+	prefixBytes, length := 0, uint64(0)
 
-		divRune, _, err := reader.ReadRune()
+decoding:
+	for !budgetExpired.Load() {
+		// Read a group of runes (a pair by default) to decode the original byte
+		first, pairOffset, err := symbols.next()
 		if err == io.EOF {
-			return fmt.Errorf("unexpected EOF: input length is not even")
+			break
+		}
+		if err == errMessageEnd {
+			if err := opts.endMessage(); err != nil {
+				return fmt.Errorf("error writing output: %w", err)
+			}
+			groups = 0
+			continue
 		}
 		if err != nil {
 			return fmt.Errorf("error reading input: %w", err)
 		}
+		group[0] = first
+		pairRune := symbols.symbolRune
+		odd := groups%2 == 1
+		groups++
 
-		// Ignore line breaks or other unexpected characters
-		if divRune == '\r' || divRune == '\n' {
-			continue // Skip line breaks
+		for i := 1; i < len(group); i++ {
+			group[i], _, err = symbols.next()
+			if err == io.EOF && budgetExpired.Load() {
+				// The budget cut the input short inside a group
+				break decoding
+			}
+			if err == io.EOF {
+				return fmt.Errorf("unexpected EOF at offset %d: input length is not a multiple of %d", symbols.offset, opts.spb)
+			}
+			if err == errMessageEnd {
+				return fmt.Errorf("message delimiter inside a symbol pair at offset %d", pairOffset)
+			}
+			if err != nil {
+				return fmt.Errorf("error reading input: %w", err)
+			}
 		}
 
-		// Map the runes back to bytes
-		rem, remOk := decodeMap[remRune]
-		div, divOk := decodeMap[divRune]
+		// Map the runes back to digits and reconstruct the original byte,
+		// starting from the most significant digit at the end
+		value := 0
+		for i := len(group) - 1; i >= 0; i-- {
+			digit, ok := decodeMap[group[i]]
+			if ok && opts.oddMap != nil {
+				_, inOdd := opts.oddMap[group[i]]
+				ok = inOdd == odd
+			}
+			if !ok && opts.replaced != nil {
+				clearProgress()
+				fmt.Fprintf(os.Stderr, "Warning: replaced invalid character at offset %d\n", pairOffset)
+				opts.replaced.Add(1)
+				value = int(opts.replacement)
+				break
+			}
+			if !ok {
+				return fmt.Errorf("invalid character in input at offset %d", pairOffset)
+			}
+			value = value*base + int(digit)
+		}
+		if value > 255 {
+			switch opts.overflow {
+			case "wrap":
+				// Keep the low eight bits
+			case "skip":
+				continue
+			default:
+				return fmt.Errorf("decoded value %d out of byte range at offset %d", value, pairOffset)
+			}
+		}
+		originalByte := byte(value)
 
-		if !remOk || !divOk {
-			return fmt.Errorf("invalid character in input")
+		if opts.lengthPrefix && prefixBytes < lengthPrefixSize {
+			length = length<<8 | uint64(originalByte)
+			prefixBytes++
+			if prefixBytes == lengthPrefixSize && length == 0 {
+				break
+			}
+			continue
 		}
 
-		// Reconstruct the original byte
-		base := byte(30)
-		originalByte := div*base + rem
+		if opts.offsetMap != nil && totalBytes%opts.offsetInterval == 0 {
+			if _, err := fmt.Fprintf(opts.offsetMap, "%d,%d,%d\n", pairOffset, pairRune, totalBytes); err != nil {
+				return fmt.Errorf("error writing offset map: %w", err)
+			}
+		}
 
 		if err := writer.WriteByte(originalByte); err != nil {
 			return fmt.Errorf("error writing output: %w", err)
 		}
 
 		totalBytes++
-		if totalBytes%bufferSize == 0 {
-			fmt.Fprintf(os.Stderr, "\rProcessed: %d MB", totalBytes/1024/1024)
+		if !opts.quiet {
+			reportProgress(totalBytes)
+		}
+
+		if opts.lengthPrefix && uint64(totalBytes) == length {
+			break
+		}
+	}
+
+	if opts.digest != nil && symbols.digest != "" {
+		*opts.digest = symbols.digest
+	}
+
+	if opts.offsetMap != nil {
+		if _, err := fmt.Fprintf(opts.offsetMap, "%d,%d,%d\n", symbols.offset, symbols.runes, totalBytes); err != nil {
+			return fmt.Errorf("error writing offset map: %w", err)
+		}
+	}
+
+	if opts.lengthPrefix && !budgetExpired.Load() {
+		if prefixBytes < lengthPrefixSize {
+			return fmt.Errorf("input ended inside the length prefix")
+		}
+		if uint64(totalBytes) < length {
+			return fmt.Errorf("input ended after %d of %d bytes", totalBytes, length)
 		}
 	}
 
-	fmt.Fprint(os.Stderr, "\n")
+	if !opts.quiet {
+		endProgress()
+		reportBudget(totalBytes)
+	}
 	return nil
 }
+
+// errMessageEnd is returned by symbolReader.next at a message delimiter.
+var errMessageEnd = errors.New("end of message")
+
+// symbolReader yields the data runes of encoded input, skipping line breaks
+// and an optional separator byte sequence.
+type symbolReader struct {
+	r      *bufio.Reader
+	sep    []byte
+	ignore []byte
+	delim  []byte
+	offset int // input bytes consumed so far
+	runes  int // input runes consumed so far
+
+	// symbolRune is the rune offset of the symbol last returned by next.
+	symbolRune int
+
+	aliases []alias // longest first
+
+	// numbered lines start with "NNN: ", which is skipped; midLine is
+	// set once the start of the current line has been handled.
+	numbered bool
+	midLine  bool
+
+	marker rune // skipped like a line break if non-zero
+	pad    rune // likewise
+
+	// maxLine, if set, limits the bytes between line breaks or
+	// separators; lineStart is the offset where the current line began.
+	maxLine   int
+	lineStart int
+
+	// comments enables skipping lines that start with commentMarker;
+	// digest is the digest of the last -digest-line among them.
+	comments bool
+	digest   string
+
+	fold map[rune]byte // if set, variants of its symbols are folded to them
+}
+
+// newSymbolReader returns a symbolReader for encoded input read with the
+// settings of opts. Checkpoint and block markers, padding and comment
+// lines are skipped unless the alphabet uses their runes as symbols.
+func newSymbolReader(reader *bufio.Reader, decodeMap map[rune]byte, opts decodeOptions) *symbolReader {
+	symbols := &symbolReader{r: reader, sep: opts.sep, ignore: opts.ignore, delim: opts.delim, aliases: opts.aliases, numbered: opts.lineNumbers, maxLine: opts.maxLine}
+	if opts.fold {
+		symbols.fold = decodeMap
+	}
+	if _, ok := decodeMap[checkpointMarker]; !ok {
+		symbols.marker = checkpointMarker
+	}
+	if _, ok := decodeMap[padRune]; !ok {
+		symbols.pad = padRune
+	}
+	if _, ok := decodeMap[commentMarker]; !ok {
+		symbols.comments = true
+	}
+	return symbols
+}
+
+// next returns the next data rune and the input offset it started at.
+func (s *symbolReader) next() (rune, int, error) {
+	for {
+		if s.maxLine > 0 && s.offset-s.lineStart > s.maxLine {
+			return 0, s.offset, fmt.Errorf("line at offset %d is longer than %d bytes", s.lineStart, s.maxLine)
+		}
+		if !s.midLine {
+			if s.comments && s.skipComment() {
+				continue
+			}
+			if s.numbered {
+				s.skipLineNumber()
+			}
+			s.midLine = true
+		}
+		if len(s.delim) > 0 {
+			if b, _ := s.r.Peek(len(s.delim)); bytes.Equal(b, s.delim) {
+				s.skip(s.delim)
+				return 0, s.offset - len(s.delim), errMessageEnd
+			}
+		}
+		if len(s.sep) > 0 {
+			if b, _ := s.r.Peek(len(s.sep)); bytes.Equal(b, s.sep) {
+				s.skip(s.sep)
+				s.midLine = false
+				s.lineStart = s.offset
+				continue
+			}
+		}
+		if len(s.ignore) > 0 {
+			if b, _ := s.r.Peek(len(s.ignore)); bytes.Equal(b, s.ignore) {
+				s.skip(s.ignore)
+				continue
+			}
+		}
+
+		start := s.offset
+		s.symbolRune = s.runes
+		if r, ok := s.matchAlias(); ok {
+			return r, start, nil
+		}
+		r, size, err := s.r.ReadRune()
+		if err != nil {
+			return 0, start, err
+		}
+		s.offset += size
+		s.runes++
+
+		// Skip line breaks
+		if r == '\r' || r == '\n' {
+			s.midLine = false
+			s.lineStart = s.offset
+			continue
+		}
+		if (s.marker != 0 && r == s.marker) || (s.pad != 0 && r == s.pad) {
+			continue
+		}
+		if s.fold != nil {
+			r, _ = foldSymbol(r, s.fold)
+		}
+		return r, start, nil
+	}
+}
+
+// maxLineNumber bounds the digits skipped as a line number.
+const maxLineNumber = 20
+
+// skipLineNumber consumes a line number prefix: digits followed by ": ".
+func (s *symbolReader) skipLineNumber() {
+	b, _ := s.r.Peek(maxLineNumber + 2)
+	digits := 0
+	for digits < len(b) && b[digits] >= '0' && b[digits] <= '9' {
+		digits++
+	}
+	if digits > 0 && bytes.HasPrefix(b[digits:], []byte(": ")) {
+		s.skip(b[:digits+2])
+	}
+}
+
+// commentMarker starts the comment lines written by -ruler and
+// -digest-line. Decode skips them wherever it is not an alphabet symbol.
+const commentMarker = '#'
+
+// skipComment consumes a comment line at the current position, keeping
+// the digest of a digest line. It reports whether there was one.
+func (s *symbolReader) skipComment() bool {
+	if b, _ := s.r.Peek(1); len(b) == 0 || b[0] != commentMarker {
+		return false
+	}
+	line, _ := s.r.ReadString('\n')
+	s.offset += len(line)
+	s.runes += utf8.RuneCountInString(line)
+	if strings.HasPrefix(line, digestMarker) {
+		s.digest = strings.TrimSpace(line[len(digestMarker):])
+	}
+	return true
+}
+
+// skip consumes seq, which must be next in the input.
+func (s *symbolReader) skip(seq []byte) {
+	s.offset += len(seq)
+	s.runes += utf8.RuneCount(seq)
+	s.r.Discard(len(seq))
+}
+
+// matchAlias consumes the longest alias at the current position and
+// returns the symbol it stands for.
+func (s *symbolReader) matchAlias() (rune, bool) {
+	for _, a := range s.aliases {
+		if b, _ := s.r.Peek(len(a.seq)); bytes.Equal(b, a.seq) {
+			s.skip(a.seq)
+			return a.symbol, true
+		}
+	}
+	return 0, false
+}
+
+// parseSeparator unquotes a Go-escaped separator such as \x1e or \r\n and
+// makes sure it cannot be mistaken for encoded data.
+func parseSeparator(escaped string, decodeMap map[rune]byte) (string, error) {
+	sep, err := strconv.Unquote(`"` + escaped + `"`)
+	if err != nil {
+		return "", fmt.Errorf("cannot unescape %q", escaped)
+	}
+	for _, r := range sep {
+		if _, ok := decodeMap[r]; ok {
+			return "", fmt.Errorf("separator contains alphabet symbol %q", r)
+		}
+	}
+	return sep, nil
+}
@@ -0,0 +1,344 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// TestMain runs the command itself instead of the tests when runMain
+// starts the test binary, so tests can check whole runs.
+func TestMain(m *testing.M) {
+	if os.Getenv("C30_TEST_MAIN") == "1" {
+		main()
+		os.Exit(0)
+	}
+	os.Exit(m.Run())
+}
+
+// runMain runs the command with args and stdin, in the directory dir if
+// it is not empty, and returns its output and exit code.
+func runMain(t *testing.T, dir, stdin string, args ...string) (stdout, stderr string, code int) {
+	t.Helper()
+	cmd := exec.Command(os.Args[0], args...)
+	cmd.Dir = dir
+	cmd.Env = append(os.Environ(), "C30_TEST_MAIN=1")
+	cmd.Stdin = strings.NewReader(stdin)
+	var out, errOut bytes.Buffer
+	cmd.Stdout, cmd.Stderr = &out, &errOut
+	err := cmd.Run()
+	var exit *exec.ExitError
+	switch {
+	case errors.As(err, &exit):
+		code = exit.ExitCode()
+	case err != nil:
+		t.Fatal(err)
+	}
+	return out.String(), errOut.String(), code
+}
+
+// encodeString encodes data with the given map and options.
+func encodeString(t *testing.T, data []byte, encodeMap map[byte]rune, opts encodeOptions) string {
+	t.Helper()
+	var encoded bytes.Buffer
+	w := bufio.NewWriter(&encoded)
+	if err := encode(bufio.NewReader(bytes.NewReader(data)), w, encodeMap, opts); err != nil {
+		t.Fatalf("encode: %v", err)
+	}
+	w.Flush()
+	return encoded.String()
+}
+
+// decodeString decodes text with the given map and options.
+func decodeString(text string, decodeMap map[rune]byte, opts decodeOptions) ([]byte, error) {
+	var decoded bytes.Buffer
+	w := bufio.NewWriter(&decoded)
+	err := decode(bufio.NewReader(strings.NewReader(text)), w, decodeMap, opts)
+	w.Flush()
+	return decoded.Bytes(), err
+}
+
+func TestRoundTrip(t *testing.T) {
+	all := make([]byte, 256)
+	for i := range all {
+		all[i] = byte(i)
+	}
+	tests := []struct {
+		name     string
+		alphabet string
+		data     []byte
+		enc      encodeOptions
+	}{
+		{"empty", defaultAlphabet, nil, encodeOptions{spb: 2}},
+		{"all bytes", defaultAlphabet, all, encodeOptions{spb: 2}},
+		{"ascii alphabet", "0123456789abcdefghijklmnopqrst", all, encodeOptions{spb: 2}},
+		{"three symbols", defaultAlphabet, all, encodeOptions{spb: 3}},
+		{"eight symbols", defaultAlphabet, all, encodeOptions{spb: 8}},
+		{"wrapped", defaultAlphabet, all, encodeOptions{spb: 2, width: 60, eol: "\r\n"}},
+		{"odd width", defaultAlphabet, all, encodeOptions{spb: 2, width: 7, eol: "\r\n"}},
+		{"final eol", defaultAlphabet, all, encodeOptions{spb: 2, width: 7, eol: "\r\n", finalEOL: true}},
+		{"raw eol", defaultAlphabet, all, encodeOptions{spb: 2, width: 60, eol: "\x1e", finalEOL: true}},
+		{"padded", defaultAlphabet, all, encodeOptions{spb: 2, width: 50, eol: "\n", padFinal: true}},
+		{"line numbers", defaultAlphabet, all, encodeOptions{spb: 2, width: 40, eol: "\n", lineNumbers: true}},
+		{"multi-byte eol", defaultAlphabet, all, encodeOptions{spb: 3, width: 60, eol: "|\x00|"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			encodeMap, decodeMap, err := createMaps(tt.alphabet)
+			if err != nil {
+				t.Fatal(err)
+			}
+			encoded := encodeString(t, tt.data, encodeMap, tt.enc)
+			symbols := 0
+			for _, r := range encoded {
+				if _, ok := decodeMap[r]; ok {
+					symbols++
+				}
+			}
+			if want := len(tt.data) * tt.enc.spb; symbols != want {
+				t.Errorf("%d symbols, want %d", symbols, want)
+			}
+			if tt.enc.finalEOL && len(tt.data) > 0 && !strings.HasSuffix(encoded, tt.enc.eol) {
+				t.Errorf("partial last line not terminated: %q", encoded)
+			}
+			decoded, err := decodeString(encoded, decodeMap, decodeOptions{overflow: "error", spb: tt.enc.spb, sep: []byte(tt.enc.eol), lineNumbers: tt.enc.lineNumbers})
+			if err != nil {
+				t.Fatalf("decode: %v", err)
+			}
+			if !bytes.Equal(decoded, tt.data) {
+				t.Errorf("round trip gave %v, want %v", decoded, tt.data)
+			}
+		})
+	}
+}
+
+func TestCreateMapsErrors(t *testing.T) {
+	for _, alphabet := range []string{
+		"",
+		defaultAlphabet[:len(defaultAlphabet)-len("ẞ")],
+		defaultAlphabet + "0",
+		"AACDEFGHIJKLMNOPQRSTUVWXYZÄÖÜẞ",
+		"\nBCDEFGHIJKLMNOPQRSTUVWXYZÄÖÜẞ",
+	} {
+		if _, _, err := createMaps(alphabet); err == nil {
+			t.Errorf("%q: no error", alphabet)
+		}
+	}
+}
+
+func TestOverflow(t *testing.T) {
+	encodeMap, decodeMap, err := createMaps(defaultAlphabet)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 27 + 28·30 = 867, which does not fit a byte; 867 & 0xff = 99
+	input := string([]rune{encodeMap[1], encodeMap[0], encodeMap[27], encodeMap[28], encodeMap[2], encodeMap[0]})
+	tests := []struct {
+		overflow string
+		want     []byte
+		wantErr  bool
+	}{
+		{"error", nil, true},
+		{"wrap", []byte{1, 99, 2}, false},
+		{"skip", []byte{1, 2}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.overflow, func(t *testing.T) {
+			got, err := decodeString(input, decodeMap, decodeOptions{overflow: tt.overflow, spb: 2})
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("no error, decoded %v", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(got, tt.want) {
+				t.Errorf("decoded %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseSeparator(t *testing.T) {
+	_, decodeMap, err := createMaps(defaultAlphabet)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		escaped string
+		want    string
+		wantErr bool
+	}{
+		{`\x1e`, "\x1e", false},
+		{`\r\n`, "\r\n", false},
+		{`|`, "|", false},
+		{`\u00a7\n`, "§\n", false},
+		{`A`, "", true},
+		{`-\u00c4-`, "", true},
+		{`\x`, "", true},
+		{`"`, "", true},
+	}
+	for _, tt := range tests {
+		got, err := parseSeparator(tt.escaped, decodeMap)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("%s: no error, got %q", tt.escaped, got)
+			}
+			continue
+		}
+		if err != nil || got != tt.want {
+			t.Errorf("%s: got %q, %v, want %q", tt.escaped, got, err, tt.want)
+		}
+	}
+}
+
+func TestSelfTest(t *testing.T) {
+	for _, alphabet := range []string{defaultAlphabet, "0123456789abcdefghijklmnopqrst"} {
+		encodeMap, decodeMap, err := createMaps(alphabet)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, spb := range []int{2, 3, 8} {
+			if err := selfTest(encodeMap, decodeMap, spb); err != nil {
+				t.Errorf("%s, spb %d: %v", alphabet, spb, err)
+			}
+		}
+	}
+}
+
+func TestCheckpoint(t *testing.T) {
+	encodeMap, decodeMap, err := createMaps(defaultAlphabet)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data := []byte(strings.Repeat("checkpointed ", 10))
+	tests := []struct {
+		name       string
+		checkpoint int
+		width      int
+		resumed    int64 // input already encoded by an earlier run
+	}{
+		{"unwrapped", 16, 0, 0},
+		{"wrapped", 16, 10, 0},
+		{"every byte", 1, 7, 0},
+		{"longer than the input", 1000, 0, 0},
+		{"whole input", len(data), 0, 0},
+		{"resumed", 16, 0, 10},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var encoded bytes.Buffer
+			w := bufio.NewWriter(&encoded)
+			var flushed []string
+			opts := encodeOptions{spb: 2, width: tt.width, eol: "\n", checkpoint: tt.checkpoint, resumedInput: tt.resumed}
+			opts.flush = func() error {
+				if err := w.Flush(); err != nil {
+					return err
+				}
+				flushed = append(flushed, encoded.String())
+				return nil
+			}
+			if err := encode(bufio.NewReader(bytes.NewReader(data)), w, encodeMap, opts); err != nil {
+				t.Fatal(err)
+			}
+			if err := w.Flush(); err != nil {
+				t.Fatal(err)
+			}
+
+			// Markers count from the start of the resumed run
+			want := (int(tt.resumed)+len(data))/tt.checkpoint - int(tt.resumed)/tt.checkpoint
+			if n := strings.Count(encoded.String(), string(checkpointMarker)); n != want || len(flushed) != want {
+				t.Fatalf("%d markers and %d flushes, want %d", n, len(flushed), want)
+			}
+			// Each flush leaves a decodable prefix ending in a marker
+			for i, prefix := range flushed {
+				if !strings.HasSuffix(prefix, string(checkpointMarker)) {
+					t.Errorf("flush %d does not end at a marker", i+1)
+				}
+				decoded, err := decodeString(prefix, decodeMap, decodeOptions{overflow: "error", spb: 2})
+				if err != nil {
+					t.Fatalf("flush %d: %v", i+1, err)
+				}
+				if end := (int(tt.resumed)/tt.checkpoint+i+1)*tt.checkpoint - int(tt.resumed); !bytes.Equal(decoded, data[:end]) {
+					t.Errorf("flush %d decoded %q, want %q", i+1, decoded, data[:end])
+				}
+			}
+			decoded, err := decodeString(encoded.String(), decodeMap, decodeOptions{overflow: "error", spb: 2})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(decoded, data) {
+				t.Errorf("round trip gave %q, want %q", decoded, data)
+			}
+		})
+	}
+}
+
+// A file that fails with -continue-on-error adds nothing to the output,
+// not even what it decoded before the failure.
+func TestContinueOnError(t *testing.T) {
+	dir := t.TempDir()
+	files := []struct {
+		name, text string
+	}{
+		{"first", "first "},
+		{"broken", "broken"},
+		{"second", "second"},
+	}
+	for _, f := range files {
+		encoded, stderr, code := runMain(t, dir, f.text, "-q")
+		if code != 0 {
+			t.Fatalf("encode %s: exit %d: %s", f.name, code, stderr)
+		}
+		if f.name == "broken" {
+			encoded += "xx"
+		}
+		if err := os.WriteFile(filepath.Join(dir, f.name), []byte(encoded), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		args     []string
+		want     string
+		wantCode int
+	}{
+		{[]string{"first", "broken", "second"}, "first second", 1},
+		{[]string{"broken"}, "", 1},
+		{[]string{"first", "second"}, "first second", 0},
+		{[]string{"-max-memory", "8M", "first", "broken", "second"}, "first second", 1},
+	}
+	for _, tt := range tests {
+		stdout, stderr, code := runMain(t, dir, "", append([]string{"-q", "-d", "-force", "-continue-on-error"}, tt.args...)...)
+		if stdout != tt.want || code != tt.wantCode {
+			t.Errorf("%v: output %q, exit %d, want %q, exit %d: %s", tt.args, stdout, code, tt.want, tt.wantCode, stderr)
+		}
+		if code != 0 && !strings.Contains(stderr, "broken") {
+			t.Errorf("%v: failure does not name the file: %s", tt.args, stderr)
+		}
+	}
+
+	// Held back output counts against -max-memory: leave room for less
+	// than one file
+	args := []string{"-q", "-d", "-force", "-continue-on-error", "-max-memory"}
+	_, stderr, _ := runMain(t, dir, "", append(args, "1", "first")...)
+	var fixed int
+	if _, need, _ := strings.Cut(stderr, "buffers "); need == "" {
+		t.Fatalf("no buffer size in %q", stderr)
+	} else if _, err := fmt.Sscanf(need, "need %d bytes", &fixed); err != nil {
+		t.Fatalf("no buffer size in %q", stderr)
+	}
+	stdout, stderr, code := runMain(t, dir, "", append(args, strconv.Itoa(fixed+4), "first", "second")...)
+	if stdout != "" || code == 0 || !strings.Contains(stderr, "-max-memory") {
+		t.Errorf("output %q, exit %d: %s", stdout, code, stderr)
+	}
+}
@@ -0,0 +1,134 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+// commandSink feeds the output to the stdin of a command, for -exec and
+// -post-cmd. The command line is split at whitespace; there is no shell
+// quoting.
+type commandSink struct {
+	cmd   *exec.Cmd
+	stdin interface {
+		Write([]byte) (int, error)
+		Close() error
+	}
+	done bool
+	err  error
+}
+
+// startCommand starts the command with its output going to stdout.
+func startCommand(line string, stdout io.Writer) (*commandSink, error) {
+	cmd, err := newCommand(line)
+	if err != nil {
+		return nil, err
+	}
+	cmd.Stdout = stdout
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, err
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, err
+	}
+	return &commandSink{cmd: cmd, stdin: stdin}, nil
+}
+
+// Write passes p on to the command. When the command has gone away, the
+// error reports how it ended rather than the broken pipe.
+func (c *commandSink) Write(p []byte) (int, error) {
+	n, err := c.stdin.Write(p)
+	if err != nil {
+		if waitErr := c.Close(); waitErr != nil {
+			return n, waitErr
+		}
+	}
+	return n, err
+}
+
+// Close ends the input of the command and waits for it to exit.
+func (c *commandSink) Close() error {
+	if c.done {
+		return c.err
+	}
+	c.done = true
+	c.stdin.Close()
+	if err := c.cmd.Wait(); err != nil {
+		c.err = fmt.Errorf("%s: %w", c.cmd.Args[0], err)
+	}
+	return c.err
+}
+
+// commandSource reads the output of a command that is fed from r, for
+// -pre-cmd.
+type commandSource struct {
+	cmd    *exec.Cmd
+	stdout io.ReadCloser
+	done   bool
+	err    error
+}
+
+func startFilter(line string, r io.Reader) (*commandSource, error) {
+	cmd, err := newCommand(line)
+	if err != nil {
+		return nil, err
+	}
+	cmd.Stdin = r
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, err
+	}
+	return &commandSource{cmd: cmd, stdout: stdout}, nil
+}
+
+// Read returns the output of the command. At its end the command is
+// waited for, so that a failure is reported instead of a short input.
+func (c *commandSource) Read(p []byte) (int, error) {
+	if c.done {
+		return 0, c.end()
+	}
+	n, err := c.stdout.Read(p)
+	if err == io.EOF {
+		c.done = true
+		if waitErr := c.cmd.Wait(); waitErr != nil {
+			c.err = fmt.Errorf("%s: %w", c.cmd.Args[0], waitErr)
+		}
+		err = c.end()
+	}
+	return n, err
+}
+
+func (c *commandSource) end() error {
+	if c.err != nil {
+		return c.err
+	}
+	return io.EOF
+}
+
+func newCommand(line string) (*exec.Cmd, error) {
+	args := strings.Fields(line)
+	if len(args) == 0 {
+		return nil, fmt.Errorf("empty command")
+	}
+	cmd := exec.Command(args[0], args[1:]...)
+	cmd.Stderr = os.Stderr
+	return cmd, nil
+}
+
+// commandExitCode is the status c30 exits with after an external command
+// failed with err: the command's own code when it has one, else 1.
+func commandExitCode(err error) int {
+	var exit *exec.ExitError
+	if errors.As(err, &exit) && exit.ExitCode() > 0 {
+		return exit.ExitCode()
+	}
+	return 1
+}
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"strconv"
+)
+
+// applyConfig reads a JSON object whose keys are flag names, such as
+// {"w": 76, "preset": "transcribe", "final-eol": true}, and sets every
+// flag that was not given on the command line. Afterwards the config
+// values count as given, so the usual flag validation covers them.
+func applyConfig(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	var values map[string]interface{}
+	if err := json.Unmarshal(data, &values); err != nil {
+		return fmt.Errorf("%s: %v", path, err)
+	}
+
+	explicit := make(map[string]bool)
+	flag.Visit(func(f *flag.Flag) { explicit[f.Name] = true })
+
+	for name, value := range values {
+		if name == "config" {
+			return fmt.Errorf("%s: config files cannot include other config files", path)
+		}
+		if flag.Lookup(name) == nil {
+			return fmt.Errorf("%s: unknown flag %q", path, name)
+		}
+		if explicit[name] {
+			continue
+		}
+
+		var text string
+		switch v := value.(type) {
+		case string:
+			text = v
+		case bool:
+			text = strconv.FormatBool(v)
+		case float64:
+			text = strconv.FormatFloat(v, 'f', -1, 64)
+		default:
+			return fmt.Errorf("%s: flag %q needs a string, number or boolean", path, name)
+		}
+		if err := flag.Set(name, text); err != nil {
+			return fmt.Errorf("%s: flag %q: %v", path, name, err)
+		}
+	}
+	return nil
+}
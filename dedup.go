@@ -0,0 +1,182 @@
+package main
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+)
+
+// -dedup-block cuts the input into blocks of a fixed size and encodes
+// each distinct block once. The stream becomes a sequence of records, each
+// starting with a tag byte:
+//
+//	dedupLiteral, then the block: a block seen for the first time
+//	dedupRef, then a uvarint k: a repeat of the k-th literal block
+//	dedupTail, then a uvarint length and that many bytes: a short block
+//	at the end of an input
+//
+// Literal blocks are numbered from 0 across all inputs of a run.
+const (
+	dedupLiteral = 0
+	dedupRef     = 1
+	dedupTail    = 2
+)
+
+// dedupTable remembers the literal blocks written so far. It is shared by
+// the readers of all inputs so the numbering continues across them.
+type dedupTable struct {
+	size int
+	seen map[string]uint64
+}
+
+func newDedupTable(size int) *dedupTable {
+	return &dedupTable{size: size, seen: make(map[string]uint64)}
+}
+
+// checkDedupMemory fails once n distinct blocks of size bytes would no
+// longer fit in what -max-memory leaves for whole-input data.
+func checkDedupMemory(n, size int) error {
+	if wholeInputLimit > 0 && int64(n)*int64(size) > wholeInputLimit {
+		return fmt.Errorf("%d distinct -dedup-block blocks exceed the %d bytes left by -max-memory", n, wholeInputLimit)
+	}
+	return nil
+}
+
+// dedupReader turns the bytes of r into dedup records.
+type dedupReader struct {
+	r       io.Reader
+	table   *dedupTable
+	block   []byte
+	pending []byte
+	out     []byte
+	done    bool
+}
+
+func newDedupReader(r io.Reader, table *dedupTable) *dedupReader {
+	return &dedupReader{r: r, table: table, block: make([]byte, table.size)}
+}
+
+func (d *dedupReader) Read(p []byte) (int, error) {
+	for len(d.pending) == 0 {
+		if d.done {
+			return 0, io.EOF
+		}
+		if err := d.nextRecord(); err != nil {
+			return 0, err
+		}
+	}
+	n := copy(p, d.pending)
+	d.pending = d.pending[n:]
+	return n, nil
+}
+
+// nextRecord reads the next block and sets pending to its record.
+func (d *dedupReader) nextRecord() error {
+	n, err := io.ReadFull(d.r, d.block)
+	switch {
+	case err == io.EOF:
+		d.done = true
+		return nil
+	case err == io.ErrUnexpectedEOF:
+		d.done = true
+		d.out = append(d.out[:0], dedupTail)
+		d.out = binary.AppendUvarint(d.out, uint64(n))
+		d.out = append(d.out, d.block[:n]...)
+	case err != nil:
+		return err
+	default:
+		if k, ok := d.table.seen[string(d.block)]; ok {
+			d.out = append(d.out[:0], dedupRef)
+			d.out = binary.AppendUvarint(d.out, k)
+		} else {
+			if err := checkDedupMemory(len(d.table.seen)+1, d.table.size); err != nil {
+				return err
+			}
+			d.table.seen[string(d.block)] = uint64(len(d.table.seen))
+			d.out = append(append(d.out[:0], dedupLiteral), d.block...)
+		}
+	}
+	d.pending = d.out
+	return nil
+}
+
+// dedupWriter expands the dedup records written to it. Records may be
+// split across writes; the incomplete rest is kept until it is whole.
+type dedupWriter struct {
+	w      io.Writer
+	size   int
+	blocks [][]byte
+	buf    []byte
+	offset int64
+}
+
+func (d *dedupWriter) Write(p []byte) (int, error) {
+	d.buf = append(d.buf, p...)
+	for {
+		n, err := d.record()
+		if err != nil {
+			return 0, fmt.Errorf("%w at offset %d", err, d.offset)
+		}
+		if n == 0 {
+			break
+		}
+		d.buf = d.buf[n:]
+		d.offset += int64(n)
+	}
+	d.buf = append([]byte(nil), d.buf...)
+	return len(p), nil
+}
+
+// record expands the record at the start of buf and returns its length,
+// or 0 if it is not complete yet.
+func (d *dedupWriter) record() (int, error) {
+	if len(d.buf) == 0 {
+		return 0, nil
+	}
+	switch d.buf[0] {
+	case dedupLiteral:
+		if len(d.buf) < 1+d.size {
+			return 0, nil
+		}
+		if err := checkDedupMemory(len(d.blocks)+1, d.size); err != nil {
+			return 0, err
+		}
+		block := append([]byte(nil), d.buf[1:1+d.size]...)
+		d.blocks = append(d.blocks, block)
+		_, err := d.w.Write(block)
+		return 1 + d.size, err
+	case dedupRef:
+		k, n := binary.Uvarint(d.buf[1:])
+		if n == 0 {
+			return 0, nil
+		}
+		if n < 0 || k >= uint64(len(d.blocks)) {
+			return 0, fmt.Errorf("reference to unknown block")
+		}
+		_, err := d.w.Write(d.blocks[k])
+		return 1 + n, err
+	case dedupTail:
+		length, n := binary.Uvarint(d.buf[1:])
+		if n == 0 {
+			return 0, nil
+		}
+		if n < 0 || length >= uint64(d.size) {
+			return 0, fmt.Errorf("invalid short block length")
+		}
+		end := 1 + n + int(length)
+		if len(d.buf) < end {
+			return 0, nil
+		}
+		_, err := d.w.Write(d.buf[1+n : end])
+		return end, err
+	}
+	return 0, fmt.Errorf("unknown dedup record tag %d", d.buf[0])
+}
+
+// Close reports input that ends inside a record.
+func (d *dedupWriter) Close() error {
+	if len(d.buf) > 0 {
+		return fmt.Errorf("input ends inside a dedup record")
+	}
+	return nil
+}
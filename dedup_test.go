@@ -0,0 +1,72 @@
+package main
+
+import (
+	"bytes"
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestDedup(t *testing.T) {
+	block := strings.Repeat("x", 16)
+	tests := []struct {
+		name    string
+		inputs  []string // share one table, like the inputs of a run
+		size    int
+		shorter bool // whether the records are smaller than the input
+	}{
+		{"empty", []string{""}, 16, false},
+		{"short tail only", []string{"abc"}, 16, false},
+		{"repeated blocks", []string{strings.Repeat(block, 8)}, 16, true},
+		{"distinct blocks", []string{"0123456789abcdefghijklmnopqrstuv"}, 16, false},
+		{"repeats across inputs", []string{block + "tail", block + block}, 16, true},
+		{"split records", []string{strings.Repeat("ab", 100)}, 4, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			table := newDedupTable(tt.size)
+			var expanded bytes.Buffer
+			w := &dedupWriter{w: &expanded, size: tt.size}
+			written := 0
+			for _, input := range tt.inputs {
+				records, err := io.ReadAll(newDedupReader(strings.NewReader(input), table))
+				if err != nil {
+					t.Fatal(err)
+				}
+				written += len(records)
+				// A byte at a time, so every record is split
+				for i := range records {
+					if _, err := w.Write(records[i : i+1]); err != nil {
+						t.Fatal(err)
+					}
+				}
+			}
+			if err := w.Close(); err != nil {
+				t.Fatal(err)
+			}
+			want := strings.Join(tt.inputs, "")
+			if shorter := written < len(want); shorter != tt.shorter {
+				t.Errorf("%d bytes of records for %d input bytes", written, len(want))
+			}
+			if expanded.String() != want {
+				t.Errorf("expanded to %q, want %q", expanded.String(), want)
+			}
+		})
+	}
+}
+
+func TestDedupMemory(t *testing.T) {
+	saved := wholeInputLimit
+	t.Cleanup(func() { wholeInputLimit = saved })
+	wholeInputLimit = 40
+
+	table := newDedupTable(16)
+	_, err := io.ReadAll(newDedupReader(strings.NewReader("0123456789abcdefghijklmnopqrstuvABCDEFGHIJKLMNOP"), table))
+	if err == nil {
+		t.Fatal("three distinct blocks of 16 bytes fit in 40 bytes")
+	}
+	table = newDedupTable(16)
+	if _, err := io.ReadAll(newDedupReader(strings.NewReader(strings.Repeat("0123456789abcdef", 10)), table)); err != nil {
+		t.Errorf("repeats of one block: %v", err)
+	}
+}
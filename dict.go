@@ -0,0 +1,171 @@
+package main
+
+import (
+	"bufio"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+)
+
+// -dict replaces byte sequences listed in a dictionary file by two-byte
+// codes before encoding. Byte 0xFF is the escape: 0xFF followed by k
+// stands for dictionary entry k (0 to 254), and 0xFF 0xFF for a literal
+// 0xFF. Every other byte stands for itself, so the codes can never be
+// confused with data.
+const dictEscape = 0xFF
+
+// maxDictEntries is the number of codes available after the escape byte.
+const maxDictEntries = 255
+
+// dictEntry is a dictionary sequence and its code.
+type dictEntry struct {
+	seq  []byte
+	code byte
+}
+
+// loadDict reads a dictionary file: one hex-encoded sequence of at least
+// two bytes per line, in code order. Blank lines and lines starting with
+// '#' are ignored.
+func loadDict(path string) ([][]byte, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var entries [][]byte
+	scanner := bufio.NewScanner(f)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+		seq, err := hex.DecodeString(text)
+		if err != nil {
+			return nil, fmt.Errorf("%s:%d: %v", path, line, err)
+		}
+		if len(seq) < 2 {
+			return nil, fmt.Errorf("%s:%d: entries need at least two bytes", path, line)
+		}
+		entries = append(entries, seq)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if len(entries) > maxDictEntries {
+		return nil, fmt.Errorf("%s: %d entries, at most %d are allowed", path, len(entries), maxDictEntries)
+	}
+	return entries, nil
+}
+
+// dictReader substitutes dictionary codes into the bytes of r, preferring
+// the longest entry that matches.
+type dictReader struct {
+	r       *bufio.Reader
+	entries []dictEntry // longest first
+	maxLen  int
+	pending []byte
+	out     [2]byte
+}
+
+func newDictReader(r io.Reader, dict [][]byte) *dictReader {
+	d := &dictReader{r: bufio.NewReader(r)}
+	for i, seq := range dict {
+		d.entries = append(d.entries, dictEntry{seq: seq, code: byte(i)})
+		if len(seq) > d.maxLen {
+			d.maxLen = len(seq)
+		}
+	}
+	sort.SliceStable(d.entries, func(i, j int) bool {
+		return len(d.entries[i].seq) > len(d.entries[j].seq)
+	})
+	return d
+}
+
+func (d *dictReader) Read(p []byte) (int, error) {
+	n := 0
+	for n < len(p) {
+		if len(d.pending) == 0 {
+			if err := d.substitute(); err != nil {
+				if n > 0 {
+					return n, nil
+				}
+				return 0, err
+			}
+		}
+		copied := copy(p[n:], d.pending)
+		d.pending = d.pending[copied:]
+		n += copied
+	}
+	return n, nil
+}
+
+// substitute consumes the next entry or byte and sets pending to its
+// replacement.
+func (d *dictReader) substitute() error {
+	next, err := d.r.Peek(d.maxLen)
+	if len(next) == 0 {
+		return err
+	}
+	for _, e := range d.entries {
+		if len(next) >= len(e.seq) && string(next[:len(e.seq)]) == string(e.seq) {
+			d.r.Discard(len(e.seq))
+			d.out = [2]byte{dictEscape, e.code}
+			d.pending = d.out[:]
+			return nil
+		}
+	}
+	d.r.Discard(1)
+	d.out = [2]byte{next[0], dictEscape}
+	if next[0] == dictEscape {
+		d.pending = d.out[:]
+	} else {
+		d.pending = d.out[:1]
+	}
+	return nil
+}
+
+// dictWriter expands dictionary codes written to it.
+type dictWriter struct {
+	w       *bufio.Writer
+	dict    [][]byte
+	escaped bool
+	offset  int64
+}
+
+func (d *dictWriter) Write(p []byte) (int, error) {
+	for i, b := range p {
+		var err error
+		switch {
+		case d.escaped && b == dictEscape:
+			err = d.w.WriteByte(dictEscape)
+		case d.escaped && int(b) < len(d.dict):
+			_, err = d.w.Write(d.dict[b])
+		case d.escaped:
+			err = fmt.Errorf("unknown dictionary code %d at offset %d", b, d.offset)
+		case b == dictEscape:
+			d.escaped = true
+			d.offset++
+			continue
+		default:
+			err = d.w.WriteByte(b)
+		}
+		if err != nil {
+			return i, err
+		}
+		d.escaped = false
+		d.offset++
+	}
+	return len(p), nil
+}
+
+// Close reports input that ends between an escape and its code.
+func (d *dictWriter) Close() error {
+	if d.escaped {
+		return fmt.Errorf("input ends inside a dictionary code")
+	}
+	return nil
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestDict(t *testing.T) {
+	dict := [][]byte{[]byte("the "), []byte("the quick "), []byte("fox")}
+	tests := []struct {
+		name  string
+		input string
+		want  string // the substituted bytes
+	}{
+		{"empty", "", ""},
+		{"no entries", "abc", "abc"},
+		{"longest entry first", "the quick fox", "\xff\x01\xff\x02"},
+		{"shorter entry", "the lazy fox", "\xff\x00lazy \xff\x02"},
+		{"literal escape byte", "a\xffb", "a\xff\xffb"},
+		{"partial entry at the end", "the quic", "\xff\x00quic"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			substituted, err := io.ReadAll(newDictReader(strings.NewReader(tt.input), dict))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(substituted) != tt.want {
+				t.Errorf("substituted %q, want %q", substituted, tt.want)
+			}
+
+			var expanded bytes.Buffer
+			bw := bufio.NewWriter(&expanded)
+			w := &dictWriter{w: bw, dict: dict}
+			for i := range substituted {
+				if _, err := w.Write(substituted[i : i+1]); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if err := w.Close(); err != nil {
+				t.Fatal(err)
+			}
+			bw.Flush()
+			if expanded.String() != tt.input {
+				t.Errorf("expanded to %q, want %q", expanded.String(), tt.input)
+			}
+		})
+	}
+}
+
+func TestDictWriterErrors(t *testing.T) {
+	dict := [][]byte{[]byte("ab")}
+	for _, input := range []string{"\xff\x05", "x\xff"} {
+		w := &dictWriter{w: bufio.NewWriter(io.Discard), dict: dict}
+		_, err := w.Write([]byte(input))
+		if err == nil {
+			err = w.Close()
+		}
+		if err == nil {
+			t.Errorf("%q: no error", input)
+		}
+	}
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"io"
+	"strings"
+)
+
+// -digest-line ends the encoded output with a line holding the SHA-256 of
+// the input, "#sha256 " followed by the hex digest, for comparing by eye.
+// Decode skips it like any comment line and checks the digest with
+// -digest-line.
+const digestMarker = "#sha256 "
+
+// newDigest returns the hash for a -digest-line algorithm.
+func newDigest(algorithm string) (hash.Hash, error) {
+	if algorithm != "sha256" {
+		return nil, fmt.Errorf("unsupported algorithm %q (want sha256)", algorithm)
+	}
+	return sha256.New(), nil
+}
+
+// digestLine formats the digest line for h, without a line break.
+func digestLine(h hash.Hash) string {
+	return digestMarker + hex.EncodeToString(h.Sum(nil))
+}
+
+// checkDigest compares the digest found in the input with h.
+func checkDigest(found string, h hash.Hash) error {
+	if found == "" {
+		return fmt.Errorf("input has no %q line", strings.TrimSpace(digestMarker))
+	}
+	if got := hex.EncodeToString(h.Sum(nil)); got != found {
+		return fmt.Errorf("digest mismatch: input says %s, decoded data has %s", found, got)
+	}
+	return nil
+}
+
+// tailWriter remembers the end of what was written through it, so that
+// the digest line starts on a line of its own without leaving a blank
+// line after output that already ends with a line break.
+type tailWriter struct {
+	w    io.Writer
+	keep int
+	tail []byte
+}
+
+func (t *tailWriter) Write(p []byte) (int, error) {
+	t.tail = append(t.tail, p...)
+	if len(t.tail) > t.keep {
+		t.tail = append(t.tail[:0], t.tail[len(t.tail)-t.keep:]...)
+	}
+	return t.w.Write(p)
+}
+
+// needsBreak reports whether output was written that does not end with
+// eol.
+func (t *tailWriter) needsBreak(eol string) bool {
+	return len(t.tail) > 0 && !bytes.HasSuffix(t.tail, []byte(eol))
+}
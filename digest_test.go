@@ -0,0 +1,54 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestDigestLine(t *testing.T) {
+	dir := t.TempDir()
+	tests := []struct {
+		name     string
+		data     string
+		encode   []string
+		edit     func(encoded string) string
+		decode   []string
+		want     string
+		wantCode int
+	}{
+		{"round trip", "digest me", nil, nil, nil, "digest me", 0},
+		{"wrapped", "digest me", []string{"-w", "4"}, nil, nil, "digest me", 0},
+		// The digest covers the zeros that -trim-trailing-zeros drops
+		{"trimmed zeros", "abc\x00\x00\x00", nil, nil, []string{"-trim-trailing-zeros"}, "abc", 0},
+		{"damaged data", "digest me", nil, func(e string) string {
+			if e[0] == 'A' {
+				return "B" + e[1:]
+			}
+			return "A" + e[1:]
+		}, nil, "", 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			input := filepath.Join(dir, "input")
+			if err := os.WriteFile(input, []byte(tt.data), 0o644); err != nil {
+				t.Fatal(err)
+			}
+			encoded, stderr, code := runMain(t, "", "", append(tt.encode, "-q", "-digest-line", "sha256", input)...)
+			if code != 0 {
+				t.Fatalf("encode exit %d: %s", code, stderr)
+			}
+			if tt.edit != nil {
+				encoded = tt.edit(encoded)
+			}
+
+			decoded, stderr, code := runMain(t, "", encoded, append([]string{"-q", "-d", "-digest-line", "sha256"}, tt.decode...)...)
+			if code != tt.wantCode {
+				t.Fatalf("decode exit %d, want %d: %s", code, tt.wantCode, stderr)
+			}
+			if code == 0 && decoded != tt.want {
+				t.Errorf("decoded %q, want %q", decoded, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,144 @@
+package main
+
+import (
+	"fmt"
+	"io"
+)
+
+// -ecc protects the bytes before encoding. Every block of up to eccBlock
+// data bytes is followed by two check bytes over GF(256): P, the xor of
+// the data, and Q, the sum of α^i·d_i. Together they locate and repair
+// one corrupted byte per block, which is what a single mistyped symbol
+// turns into once decoded with -on-overflow wrap.
+const (
+	eccBlock = 32
+	eccCheck = 2
+)
+
+// gfExp and gfLog are the exponent and logarithm tables of GF(256) with
+// the polynomial x^8+x^4+x^3+x^2+1 and generator α = 2.
+var gfExp [512]byte
+var gfLog [256]int
+
+func init() {
+	x := 1
+	for i := 0; i < 255; i++ {
+		gfExp[i] = byte(x)
+		gfLog[x] = i
+		x <<= 1
+		if x&0x100 != 0 {
+			x ^= 0x11d
+		}
+	}
+	for i := 255; i < len(gfExp); i++ {
+		gfExp[i] = gfExp[i-255]
+	}
+}
+
+// eccSyndromes returns the check bytes P and Q for data.
+func eccSyndromes(data []byte) (p, q byte) {
+	for i, d := range data {
+		p ^= d
+		if d != 0 {
+			q ^= gfExp[gfLog[d]+i]
+		}
+	}
+	return p, q
+}
+
+// eccReader appends the check bytes to each block read from r.
+type eccReader struct {
+	r       io.Reader
+	block   [eccBlock + eccCheck]byte
+	pending []byte
+	err     error
+}
+
+func (e *eccReader) Read(p []byte) (int, error) {
+	for len(e.pending) == 0 {
+		if e.err != nil {
+			return 0, e.err
+		}
+		n, err := io.ReadFull(e.r, e.block[:eccBlock])
+		if err == io.ErrUnexpectedEOF {
+			err = io.EOF
+		}
+		e.err = err
+		if n > 0 {
+			e.block[n], e.block[n+1] = eccSyndromes(e.block[:n])
+			e.pending = e.block[:n+eccCheck]
+		}
+	}
+	n := copy(p, e.pending)
+	e.pending = e.pending[n:]
+	return n, nil
+}
+
+// eccWriter checks and repairs the blocks written to it and passes on the
+// data bytes without their check bytes.
+type eccWriter struct {
+	w         io.Writer
+	block     []byte
+	offset    int64 // of the current block in the decoded stream
+	corrected int
+}
+
+func (e *eccWriter) Write(p []byte) (int, error) {
+	written := len(p)
+	for len(p) > 0 {
+		n := copy(e.block[len(e.block):cap(e.block)], p)
+		e.block = e.block[:len(e.block)+n]
+		p = p[n:]
+		if len(e.block) == cap(e.block) {
+			if err := e.flushBlock(); err != nil {
+				return 0, err
+			}
+		}
+	}
+	return written, nil
+}
+
+// flushBlock repairs the buffered block and writes its data bytes.
+func (e *eccWriter) flushBlock() error {
+	n := len(e.block) - eccCheck
+	if n <= 0 {
+		return fmt.Errorf("truncated ecc block at offset %d", e.offset)
+	}
+	data := e.block[:n]
+	p, q := eccSyndromes(data)
+	s0, s1 := p^e.block[n], q^e.block[n+1]
+
+	switch {
+	case s0 == 0 && s1 == 0:
+	case s0 == 0 || s1 == 0:
+		// Only a check byte is damaged; the data is intact
+		e.corrected++
+	default:
+		i := (gfLog[s1] - gfLog[s0] + 255) % 255
+		if i >= n {
+			return fmt.Errorf("uncorrectable ecc block at offset %d", e.offset)
+		}
+		data[i] ^= s0
+		e.corrected++
+	}
+
+	if _, err := e.w.Write(data); err != nil {
+		return err
+	}
+	e.offset += int64(len(e.block))
+	e.block = e.block[:0]
+	return nil
+}
+
+// Close checks the final, possibly short block.
+func (e *eccWriter) Close() error {
+	if len(e.block) == 0 {
+		return nil
+	}
+	return e.flushBlock()
+}
+
+// newECCWriter returns an eccWriter passing data on to w.
+func newECCWriter(w io.Writer) *eccWriter {
+	return &eccWriter{w: w, block: make([]byte, 0, eccBlock+eccCheck)}
+}
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"bytes"
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestECC(t *testing.T) {
+	data := []byte(strings.Repeat("The quick brown fox jumps over the lazy dog. ", 3))
+	tests := []struct {
+		name          string
+		corrupt       []int // offsets in the protected stream
+		wantCorrected int
+		wantErr       bool
+	}{
+		{"intact", nil, 0, false},
+		{"first data byte", []int{0}, 1, false},
+		{"last data byte", []int{eccBlock - 1}, 1, false},
+		{"check byte", []int{eccBlock}, 1, false},
+		{"one per block", []int{3, eccBlock + eccCheck + 17}, 2, false},
+		{"short final block", []int{2*(eccBlock+eccCheck) + 1}, 1, false},
+		{"two in one block", []int{3, 9}, 0, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			protected, err := io.ReadAll(&eccReader{r: bytes.NewReader(data)})
+			if err != nil {
+				t.Fatal(err)
+			}
+			blocks := (len(data) + eccBlock - 1) / eccBlock
+			if want := len(data) + blocks*eccCheck; len(protected) != want {
+				t.Fatalf("%d protected bytes, want %d", len(protected), want)
+			}
+			for k, i := range tt.corrupt {
+				protected[i] ^= byte(0x5a + k)
+			}
+
+			var repaired bytes.Buffer
+			w := newECCWriter(&repaired)
+			_, err = w.Write(protected)
+			if err == nil {
+				err = w.Close()
+			}
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("no error, repaired %q", repaired.Bytes())
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(repaired.Bytes(), data) {
+				t.Errorf("repaired %q, want %q", repaired.Bytes(), data)
+			}
+			if w.corrected != tt.wantCorrected {
+				t.Errorf("corrected %d blocks, want %d", w.corrected, tt.wantCorrected)
+			}
+		})
+	}
+}
+
+// A mistyped symbol decoded with -on-overflow wrap is repaired by -ecc.
+func TestECCMistypedSymbol(t *testing.T) {
+	encodeMap, decodeMap, err := createMaps(defaultAlphabet)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data := []byte("Code30 with error correction")
+	protected, err := io.ReadAll(&eccReader{r: bytes.NewReader(data)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	encoded := []rune(encodeString(t, protected, encodeMap, encodeOptions{spb: 2}))
+	for i, r := range encoded {
+		if r != encodeMap[29] {
+			encoded[i] = encodeMap[29]
+			break
+		}
+	}
+	decoded, err := decodeString(string(encoded), decodeMap, decodeOptions{overflow: "wrap", spb: 2, quiet: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var repaired bytes.Buffer
+	w := newECCWriter(&repaired)
+	if _, err := w.Write(decoded); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(repaired.Bytes(), data) {
+		t.Errorf("repaired %q, want %q", repaired.Bytes(), data)
+	}
+}
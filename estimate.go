@@ -0,0 +1,76 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"time"
+)
+
+// encodedLen is the number of symbols encode writes for n input bytes,
+// not counting line breaks, delimiters or a -prefix.
+func encodedLen(n int64, spb int) int64 {
+	return n * int64(spb)
+}
+
+// codecInput is the number of bytes the codec encodes for size input
+// bytes, after -ecc added its check bytes and -length-prefix its header.
+func codecInput(size int64, ecc, lengthPrefix bool) int64 {
+	if ecc {
+		size += (size + eccBlock - 1) / eccBlock * eccCheck
+	}
+	if lengthPrefix {
+		size += lengthPrefixSize
+	}
+	return size
+}
+
+// encodedLines is the number of lines encode writes for n codec bytes
+// with wrapping. A line is only ended after a whole symbol group, so it
+// holds width symbols rounded up to a multiple of the group size. With
+// -qr-segments, every segment is wrapped on its own after a header line.
+func encodedLines(n int64, enc encodeOptions, segments int) int64 {
+	perLine := int64((enc.width + enc.spb - 1) / enc.spb * enc.spb)
+	lines := func(symbols int64) int64 {
+		return (symbols + perLine - 1) / perLine
+	}
+	if segments == 0 {
+		return lines(encodedLen(n, enc.spb))
+	}
+	var total, start int64
+	for i := int64(1); i <= int64(segments); i++ {
+		end := n * i / int64(segments)
+		total += 1 + lines(encodedLen(end-start, enc.spb))
+		start = end
+	}
+	return total
+}
+
+// inputSize adds up the sizes of the input files, for -count-symbols.
+func inputSize(names []string) (int64, error) {
+	var total int64
+	for _, name := range names {
+		info, err := os.Stat(name)
+		if err != nil {
+			return 0, err
+		}
+		if !info.Mode().IsRegular() {
+			return 0, fmt.Errorf("%s: size unknown, use -size", name)
+		}
+		total += info.Size()
+	}
+	return total, nil
+}
+
+// transcriptionEstimate describes the encoded size of size input bytes,
+// which the codec sees as codec bytes, and how long typing it by hand
+// takes at spm symbols per minute.
+func transcriptionEstimate(size, codec int64, enc encodeOptions, segments int, spm float64) string {
+	symbols := encodedLen(codec, enc.spb)
+	text := fmt.Sprintf("%d bytes encode to %d symbols", size, symbols)
+	if enc.width > 0 {
+		text += fmt.Sprintf(" on %d lines", encodedLines(codec, enc, segments))
+	}
+	minutes := float64(symbols) / spm
+	duration := time.Duration(minutes * float64(time.Minute)).Round(time.Second)
+	return text + fmt.Sprintf(", about %v to transcribe at %g symbols per minute\n", duration, spm)
+}
@@ -0,0 +1,51 @@
+package main
+
+import (
+	"bytes"
+	"io"
+	"strings"
+	"testing"
+)
+
+// encodedLines must match what encode writes, or -count-lines lies.
+func TestEncodedLines(t *testing.T) {
+	encodeMap, _, err := createMaps(defaultAlphabet)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		n   int
+		enc encodeOptions
+	}{
+		{1, encodeOptions{width: 60, spb: 2}},
+		{30, encodeOptions{width: 60, spb: 2}},
+		{31, encodeOptions{width: 60, spb: 2}},
+		{100, encodeOptions{width: 7, spb: 2}},
+		{100, encodeOptions{width: 10, spb: 3}},
+		{1000, encodeOptions{width: 76, spb: 8}},
+	}
+	for _, tt := range tests {
+		tt.enc.eol, tt.enc.finalEOL = "\n", true
+		encoded := encodeString(t, bytes.Repeat([]byte{7}, tt.n), encodeMap, tt.enc)
+		got := int64(strings.Count(encoded, "\n"))
+		if want := encodedLines(int64(tt.n), tt.enc, 0); got != want {
+			t.Errorf("%d bytes at width %d, spb %d: encode wrote %d lines, estimate %d",
+				tt.n, tt.enc.width, tt.enc.spb, got, want)
+		}
+	}
+}
+
+func TestCodecInput(t *testing.T) {
+	for _, size := range []int64{0, 1, eccBlock - 1, eccBlock, eccBlock + 1, 1000} {
+		protected, err := io.ReadAll(&eccReader{r: bytes.NewReader(make([]byte, size))})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := codecInput(size, true, false); got != int64(len(protected)) {
+			t.Errorf("%d bytes with -ecc: estimate %d, eccReader wrote %d", size, got, len(protected))
+		}
+		if got, want := codecInput(size, true, true), int64(len(protected))+lengthPrefixSize; got != want {
+			t.Errorf("%d bytes with -ecc and -length-prefix: estimate %d, want %d", size, got, want)
+		}
+	}
+}
@@ -0,0 +1,257 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// explainPlan describes in words what a run with the resolved settings
+// would do, for -explain.
+func explainPlan(alphabet string, enc encodeOptions, dec decodeOptions) string {
+	var plan []string
+	add := func(format string, a ...interface{}) {
+		plan = append(plan, fmt.Sprintf(format, a...))
+	}
+
+	input := "stdin"
+	switch {
+	case *urlFlag != "":
+		input = *urlFlag
+	case *idleFlag > 0:
+		input = fmt.Sprintf("stdin in records that end after a pause of %v", *idleFlag)
+	case flag.NArg() > 0:
+		input = strings.Join(flag.Args(), ", ")
+	}
+	mode := "Encode"
+	switch {
+	case *decodeFlag && *inspectFlag:
+		mode = "Inspect the encoded"
+	case *decodeFlag && *parallelFlag:
+		mode = "Decode in parallel segments"
+	case *decodeFlag:
+		mode = "Decode"
+	case *rewrapFlag:
+		mode = "Re-wrap the encoded"
+	}
+	add("%s %s", mode, input)
+
+	name := "a custom alphabet"
+	switch {
+	case *presetFlag != "":
+		name = "the " + *presetFlag + " preset"
+	case alphabet == defaultAlphabet:
+		name = "the German alphabet"
+	}
+	if *altFlag != "" {
+		name = "alternating alphabets"
+		alphabet = *altFlag
+	}
+	add("using base 30 with %s (%s), %d symbols per byte", name, alphabet, enc.spb)
+	if *inferFlag {
+		add("with the preset inferred from each input")
+	}
+
+	if *decodeFlag {
+		add("on groups above 255: %s", dec.overflow)
+		if dec.sep != nil {
+			add("skipping the separator %s", strconv.Quote(string(dec.sep)))
+		}
+		if dec.ignore != nil {
+			add("ignoring %s", strconv.Quote(string(dec.ignore)))
+		}
+		if dec.fold {
+			add("accepting fullwidth and case variants of the symbols")
+		}
+		if dec.replaced != nil {
+			add("replacing invalid groups by byte %d", dec.replacement)
+		}
+		if dec.aliases != nil {
+			add("accepting the aliases %s", *aliasFlag)
+		}
+		if dec.maxLine > 0 {
+			add("failing on lines longer than %d bytes", dec.maxLine)
+		}
+		if *reverseFlag {
+			add("reading the symbols back to front")
+		}
+	} else {
+		if enc.width > 0 {
+			wrap := fmt.Sprintf("wrap at %d runes with %s", enc.width, strconv.Quote(enc.eol))
+			if enc.finalEOL {
+				wrap += ", including the last line"
+			}
+			add("%s", wrap)
+		} else {
+			add("without line wrapping")
+		}
+		if enc.groupDelimiter != "" {
+			add("separating groups of %d symbols with %s", enc.groupSize, strconv.Quote(enc.groupDelimiter))
+		}
+		if enc.lineNumbers {
+			add("numbering lines")
+		}
+		if enc.checkpoint > 0 {
+			add("writing a checkpoint every %d bytes", enc.checkpoint)
+		}
+		if enc.block > 0 {
+			add("marking a block boundary every %d bytes", enc.block)
+		}
+		if *linesFlag > 0 {
+			add("spread over %d lines", *linesFlag)
+		}
+		if *qrFlag > 0 {
+			add("split into %d QR segments", *qrFlag)
+		}
+		if *rulerFlag {
+			add("starting with a column ruler")
+		}
+		if enc.padFinal {
+			add("padding the last line with %q", padRune)
+		}
+		if *reverseFlag {
+			add("writing the symbols back to front")
+		}
+	}
+	if *prefixFlag != "" {
+		add("with the prefix %q", *prefixFlag)
+	}
+	if *lengthFlag {
+		add("with a length prefix")
+	}
+
+	// The byte stages, in the order they apply to the data
+	var stages []string
+	if *decodeFlag {
+		stages = decodeStages()
+	} else {
+		stages = encodeStages()
+	}
+	if len(stages) > 0 {
+		add("%s", strings.Join(stages, ", then "))
+	}
+
+	switch {
+	case *rotateFlag != "":
+		add("write to a new %s%s file every %s", *rotNameFlag, rotateSuffix, *rotateFlag)
+	case *outputFlag != "" && *resumeFlag:
+		add("resume writing %s", *outputFlag)
+	case *outputFlag != "" && *appendFlag:
+		add("append to %s", *outputFlag)
+	case *outputFlag != "":
+		add("write to %s", *outputFlag)
+	case *decodeFlag && dec.delim != nil:
+		add("write each message to %s", *templateFlag)
+	case *execFlag != "":
+		add("pipe the output into %s", *execFlag)
+	default:
+		add("write to stdout")
+	}
+	if *indexFlag != "" && !*decodeFlag {
+		add("write an index to %s", *indexFlag)
+	}
+	if *offsetFlag != "" && *decodeFlag {
+		add("write an offset map to %s", *offsetFlag)
+	}
+	if *manifestFlag != "" {
+		add("write a manifest to %s", *manifestFlag)
+	}
+	if *digestFlag != "" && !*decodeFlag {
+		add("end with a %s digest line", *digestFlag)
+	}
+	return strings.Join(plan, ",\n") + ".\n"
+}
+
+// encodeStages lists the transforms openInput applies to each input before
+// encoding, in order.
+func encodeStages() []string {
+	var stages []string
+	if *fromHexFlag {
+		stages = append(stages, "read hex digits")
+	}
+	if *fromB64Flag {
+		stages = append(stages, "read base64")
+	}
+	if *preCmdFlag != "" {
+		stages = append(stages, "pipe the input through "+*preCmdFlag)
+	}
+	if *recordFlag > 0 {
+		stages = append(stages, fmt.Sprintf("require a multiple of %d bytes", *recordFlag))
+	}
+	if *dedupFlag > 0 {
+		stages = append(stages, fmt.Sprintf("replace repeated %d-byte blocks by references", *dedupFlag))
+	}
+	if *dictFlag != "" {
+		stages = append(stages, "apply the dictionary "+*dictFlag)
+	}
+	if *pipelineFlag != "" {
+		stages = append(stages, "run the pipeline "+*pipelineFlag)
+	}
+	if *eccFlag {
+		stages = append(stages, fmt.Sprintf("add ecc check bytes every %d bytes", eccBlock))
+	}
+	return stages
+}
+
+// decodeStages lists the transforms the output chain applies to the
+// decoded bytes, in order; it pushes them the other way round.
+func decodeStages() []string {
+	var stages []string
+	if *eccFlag {
+		stages = append(stages, fmt.Sprintf("repair with the ecc check bytes every %d bytes", eccBlock))
+	}
+	if *autoGzipFlag {
+		stages = append(stages, "gunzip gzip data")
+	}
+	if *pipelineFlag != "" {
+		stages = append(stages, "undo the pipeline "+*pipelineFlag)
+	}
+	if *dictFlag != "" {
+		stages = append(stages, "expand the dictionary "+*dictFlag)
+	}
+	if *dedupFlag > 0 {
+		stages = append(stages, fmt.Sprintf("expand references to %d-byte blocks", *dedupFlag))
+	}
+	if *digestFlag != "" {
+		stages = append(stages, "check the bytes against the "+*digestFlag+" digest line")
+	}
+	if *trimFlag {
+		stages = append(stages, "trim trailing zero bytes")
+	}
+	if *expectFlag != "" {
+		stages = append(stages, "check for valid "+*expectFlag)
+	}
+	if *recordFlag > 0 {
+		stages = append(stages, fmt.Sprintf("require a multiple of %d bytes", *recordFlag))
+	}
+	if *postCmdFlag != "" {
+		stages = append(stages, "pipe the bytes through "+*postCmdFlag)
+	}
+	if *startFlag > 0 || *windowFlag >= 0 {
+		window := fmt.Sprintf("skip %d bytes", *startFlag)
+		if *windowFlag >= 0 {
+			window += fmt.Sprintf(" and keep at most %d", *windowFlag)
+		}
+		stages = append(stages, window)
+	}
+	if *jsonFlag {
+		stages = append(stages, "wrap the bytes in a JSON object")
+	}
+	if *toB64Flag {
+		stages = append(stages, "write base64")
+	}
+	if *decimalFlag {
+		stages = append(stages, "list the bytes in decimal")
+	}
+	if *hexValFlag {
+		stages = append(stages, "list the bytes in hex")
+	}
+	if *toHexFlag {
+		stages = append(stages, "write hex")
+	}
+	if *goFlag != "" {
+		stages = append(stages, "declare the output as the Go variable "+*goFlag)
+	}
+	return stages
+}
@@ -0,0 +1,391 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+)
+
+// Flags that only make sense in one direction.
+var (
+	encodeOnlyFlags = []string{"w", "from-hex", "from-base64", "preview", "index", "final-eol", "entropy", "symbol-stats", "resume", "checkpoint", "block", "byte-delimiter", "rotate", "progress-bar", "lines", "count-symbols", "count-lines", "ruler", "pad-final", "pre-cmd", "idle"}
+	decodeOnlyFlags = []string{"on-overflow", "to-hex", "to-base64", "decode-to-decimal", "decode-to-hex", "start-byte", "length", "message-delimiter", "decode-to-json", "parallel-decode", "expect", "alias", "alphabet-per-file", "replace-unknown", "ignore", "inspect", "offset-map", "fold", "trim-trailing-zeros", "force", "exec", "infer", "max-line-bytes", "post-cmd"}
+)
+
+// conflictingFlags lists pairs of flags that cannot be combined.
+var conflictingFlags = [][2]string{
+	{"a", "preset"},
+	{"alphabet-per-file", "a"},
+	{"alphabet-per-file", "preset"},
+	{"rewrap", "d"},
+	{"rewrap", "from-hex"},
+	{"rewrap", "pipeline"},
+	{"rewrap", "length-prefix"},
+	{"rewrap", "prefix"},
+	{"rewrap", "index"},
+	{"rewrap", "entropy"},
+	{"rewrap", "symbol-stats"},
+	{"o", "message-delimiter"},
+	{"resume", "append"},
+	{"resume", "rewrap"},
+	{"resume", "from-hex"},
+	{"resume", "pipeline"},
+	{"resume", "length-prefix"},
+	{"resume", "prefix"},
+	{"ecc", "message-delimiter"},
+	{"ecc", "length-prefix"},
+	{"ecc", "index"},
+	{"ecc", "rewrap"},
+	{"ecc", "resume"},
+	{"reverse", "prefix"},
+	{"reverse", "message-delimiter"},
+	{"reverse", "parallel-decode"},
+	{"reverse", "index"},
+	{"reverse", "rewrap"},
+	{"reverse", "resume"},
+	{"dedup-block", "rewrap"},
+	{"dedup-block", "resume"},
+	{"dedup-block", "message-delimiter"},
+	{"dedup-block", "inspect"},
+	{"dedup-block", "rotate"},
+	{"dedup-block", "progress-bar"},
+	{"dict", "rewrap"},
+	{"dict", "resume"},
+	{"dict", "message-delimiter"},
+	{"line-numbers", "prefix"},
+	{"line-numbers", "rewrap"},
+	{"line-numbers", "resume"},
+	{"line-numbers", "reverse"},
+	{"checkpoint", "line-numbers"},
+	{"checkpoint", "rewrap"},
+	{"checkpoint", "reverse"},
+	{"checkpoint", "block"},
+	{"block", "line-numbers"},
+	{"block", "rewrap"},
+	{"block", "resume"},
+	{"block", "reverse"},
+	{"block", "qr-segments"},
+	{"block", "lines"},
+	{"byte-delimiter", "rewrap"},
+	{"byte-delimiter", "reverse"},
+	{"inspect", "to-hex"},
+	{"inspect", "decode-to-json"},
+	{"inspect", "message-delimiter"},
+	{"inspect", "parallel-decode"},
+	{"inspect", "pipeline"},
+	{"inspect", "ecc"},
+	{"inspect", "dict"},
+	{"inspect", "expect"},
+	{"inspect", "auto-gzip"},
+	{"inspect", "reverse"},
+	{"rotate", "o"},
+	{"rotate", "rewrap"},
+	{"rotate", "resume"},
+	{"rotate", "reverse"},
+	{"rotate", "prefix"},
+	{"rotate", "length-prefix"},
+	{"rotate", "index"},
+	{"rotate", "pipeline"},
+	{"rotate", "ecc"},
+	{"rotate", "dict"},
+	{"offset-map", "parallel-decode"},
+	{"offset-map", "inspect"},
+	{"progress-bar", "from-hex"},
+	{"progress-bar", "pipeline"},
+	{"progress-bar", "dict"},
+	{"progress-bar", "ecc"},
+	{"progress-bar", "q"},
+	{"pronounceable", "byte-delimiter"},
+	{"pronounceable", "ignore"},
+	{"pronounceable", "rewrap"},
+	{"pronounceable", "resume"},
+	{"pronounceable", "reverse"},
+	{"qr-segments", "eol-raw"},
+	{"qr-segments", "reverse"},
+	{"qr-segments", "rewrap"},
+	{"qr-segments", "resume"},
+	{"qr-segments", "rotate"},
+	{"qr-segments", "checkpoint"},
+	{"qr-segments", "line-numbers"},
+	{"qr-segments", "byte-delimiter"},
+	{"qr-segments", "pronounceable"},
+	{"qr-segments", "parallel-decode"},
+	{"qr-segments", "message-delimiter"},
+	{"qr-segments", "inspect"},
+	{"qr-segments", "alphabet-per-file"},
+	{"qr-segments", "prefix"},
+	{"qr-segments", "index"},
+	{"qr-segments", "offset-map"},
+	{"lines", "w"},
+	{"lines", "byte-delimiter"},
+	{"lines", "pronounceable"},
+	{"lines", "line-numbers"},
+	{"lines", "checkpoint"},
+	{"lines", "reverse"},
+	{"lines", "qr-segments"},
+	{"lines", "rotate"},
+	{"lines", "resume"},
+	{"lines", "prefix"},
+	{"lines", "index"},
+	{"lines", "rewrap"},
+	{"decode-to-decimal", "decode-to-hex"},
+	{"decode-to-decimal", "to-hex"},
+	{"decode-to-decimal", "to-base64"},
+	{"decode-to-decimal", "decode-to-json"},
+	{"decode-to-decimal", "inspect"},
+	{"decode-to-hex", "to-hex"},
+	{"decode-to-hex", "to-base64"},
+	{"decode-to-hex", "decode-to-json"},
+	{"decode-to-hex", "inspect"},
+	{"start-byte", "message-delimiter"},
+	{"start-byte", "inspect"},
+	{"length", "message-delimiter"},
+	{"length", "inspect"},
+	{"pad-final", "byte-delimiter"},
+	{"pad-final", "pronounceable"},
+	{"pad-final", "resume"},
+	{"pad-final", "rewrap"},
+	{"pad-final", "reverse"},
+	{"pad-final", "qr-segments"},
+	{"ruler", "line-numbers"},
+	{"ruler", "byte-delimiter"},
+	{"ruler", "pronounceable"},
+	{"ruler", "prefix"},
+	{"ruler", "index"},
+	{"ruler", "resume"},
+	{"ruler", "rewrap"},
+	{"ruler", "reverse"},
+	{"ruler", "rotate"},
+	{"ruler", "qr-segments"},
+	{"go-literal", "to-hex"},
+	{"go-literal", "to-base64"},
+	{"go-literal", "decode-to-decimal"},
+	{"go-literal", "decode-to-hex"},
+	{"go-literal", "decode-to-json"},
+	{"go-literal", "inspect"},
+	{"go-literal", "message-delimiter"},
+	{"go-literal", "rotate"},
+	{"go-literal", "resume"},
+	{"go-literal", "ruler"},
+	{"go-literal", "digest-line"},
+	{"alt-alphabets", "a"},
+	{"alt-alphabets", "preset"},
+	{"alt-alphabets", "infer"},
+	{"alt-alphabets", "alphabet-per-file"},
+	{"alt-alphabets", "parallel-decode"},
+	{"alt-alphabets", "resume"},
+	{"alt-alphabets", "reverse"},
+	{"alt-alphabets", "encode-int"},
+	{"alt-alphabets", "decode-int"},
+	{"alt-alphabets", "self-test"},
+	{"infer", "a"},
+	{"infer", "preset"},
+	{"infer", "alphabet-per-file"},
+	{"url", "resume"},
+	{"url", "alphabet-per-file"},
+	{"url", "continue-on-error"},
+	{"digest-line", "resume"},
+	{"digest-line", "rewrap"},
+	{"digest-line", "reverse"},
+	{"digest-line", "qr-segments"},
+	{"digest-line", "lines"},
+	{"digest-line", "rotate"},
+	{"digest-line", "inspect"},
+	{"digest-line", "message-delimiter"},
+	{"strict-length", "resume"},
+	{"strict-length", "rewrap"},
+	{"strict-length", "trim-trailing-zeros"},
+	{"exec", "o"},
+	{"exec", "message-delimiter"},
+	{"exec", "inspect"},
+	{"pre-cmd", "rewrap"},
+	{"pre-cmd", "resume"},
+	{"pre-cmd", "progress-bar"},
+	{"post-cmd", "message-delimiter"},
+	{"post-cmd", "inspect"},
+	{"idle", "w"},
+	{"idle", "lines"},
+	{"idle", "qr-segments"},
+	{"idle", "rewrap"},
+	{"idle", "resume"},
+	{"idle", "url"},
+	{"idle", "progress-bar"},
+	{"idle", "length-prefix"},
+	{"idle", "ecc"},
+	{"idle", "prefix"},
+	{"idle", "reverse"},
+	{"idle", "pipeline"},
+	{"idle", "alt-alphabets"},
+	{"encode-int", "decode-int"},
+	{"encode-int", "d"},
+	{"to-hex", "decode-to-json"},
+	{"from-hex", "from-base64"},
+	{"from-base64", "rewrap"},
+	{"from-base64", "resume"},
+	{"from-base64", "progress-bar"},
+	{"to-base64", "to-hex"},
+	{"to-base64", "decode-to-json"},
+	{"to-base64", "inspect"},
+	{"decode-to-json", "message-delimiter"},
+	{"index", "length-prefix"},
+	{"auto-gzip", "message-delimiter"},
+	{"pipeline", "message-delimiter"},
+	{"parallel-decode", "message-delimiter"},
+	{"parallel-decode", "length-prefix"},
+	{"parallel-decode", "prefix"},
+	{"parallel-decode", "alias"},
+}
+
+// requiredFlags maps a flag to another flag it depends on.
+var requiredFlags = map[string]string{
+	"out-template": "message-delimiter",
+	"append":       "o",
+	"manifest":     "rotate",
+	"ruler":        "w",
+	"count-lines":  "w",
+	"pad-final":    "w",
+	"spm":          "count-symbols",
+	"resume":       "o",
+	"rotate-name":  "rotate",
+}
+
+// flagGiven reports whether the named flag was set on the command line.
+func flagGiven(name string) bool {
+	given := false
+	flag.Visit(func(f *flag.Flag) {
+		if f.Name == name {
+			given = true
+		}
+	})
+	return given
+}
+
+// validateFlags rejects flag combinations that contradict each other or
+// would be silently ignored.
+func validateFlags() error {
+	if *decodeFlag {
+		for _, name := range encodeOnlyFlags {
+			if flagGiven(name) {
+				return fmt.Errorf("-%s cannot be used with -d", name)
+			}
+		}
+	} else {
+		for _, name := range decodeOnlyFlags {
+			if flagGiven(name) {
+				return fmt.Errorf("-%s requires -d", name)
+			}
+		}
+	}
+
+	for _, pair := range conflictingFlags {
+		if flagGiven(pair[0]) && flagGiven(pair[1]) {
+			return fmt.Errorf("-%s and -%s cannot be combined", pair[0], pair[1])
+		}
+	}
+
+	for name, needs := range requiredFlags {
+		if flagGiven(name) && !flagGiven(needs) {
+			return fmt.Errorf("-%s requires -%s", name, needs)
+		}
+	}
+
+	if flagGiven("continue-on-error") && flag.NArg() == 0 {
+		return fmt.Errorf("-continue-on-error requires input files")
+	}
+	if (flagGiven("encode-int") || flagGiven("decode-int")) && flag.NArg() > 0 {
+		return fmt.Errorf("-encode-int and -decode-int take no input files")
+	}
+	if flagGiven("final-eol") && !flagGiven("w") && !flagGiven("lines") {
+		return fmt.Errorf("-final-eol requires -w or -lines")
+	}
+	if flagGiven("url") && flag.NArg() > 0 {
+		return fmt.Errorf("-url takes no input files")
+	}
+	if flagGiven("idle") && flag.NArg() > 0 {
+		return fmt.Errorf("-idle reads stdin and takes no input files")
+	}
+	if flagGiven("idle") && *idleFlag <= 0 {
+		return fmt.Errorf("-idle must be positive")
+	}
+	for _, name := range []string{"count-symbols", "count-lines"} {
+		if flagGiven(name) && !flagGiven("size") && flag.NArg() == 0 {
+			return fmt.Errorf("-%s needs input files or -size", name)
+		}
+	}
+	for _, name := range []string{"count-symbols", "count-lines"} {
+		if !flagGiven(name) {
+			continue
+		}
+		// These change the size by an amount only the data tells
+		for _, other := range []string{"from-hex", "from-base64", "pre-cmd", "dedup-block", "dict", "pipeline"} {
+			if flagGiven(other) {
+				return fmt.Errorf("-%s cannot predict the size with -%s", name, other)
+			}
+		}
+	}
+	if flagGiven("size") && !flagGiven("count-symbols") && !flagGiven("count-lines") {
+		return fmt.Errorf("-size requires -count-symbols or -count-lines")
+	}
+	if flagGiven("spm") && *spmFlag <= 0 {
+		return fmt.Errorf("-spm must be positive")
+	}
+	if flagGiven("index-interval") && !flagGiven("index") && !flagGiven("offset-map") {
+		return fmt.Errorf("-index-interval requires -index or -offset-map")
+	}
+	if flagGiven("line-numbers") && !*decodeFlag && !flagGiven("w") {
+		return fmt.Errorf("-line-numbers requires -w when encoding")
+	}
+	if flagGiven("resume") && !flagGiven("index") {
+		for _, name := range []string{"checkpoint", "byte-delimiter"} {
+			if flagGiven(name) {
+				return fmt.Errorf("-resume with -%s requires -index", name)
+			}
+		}
+	}
+	if flagGiven("resume") && flag.NArg() > 1 {
+		return fmt.Errorf("-resume takes at most one input file")
+	}
+	// These treat the input as a whole; several files would each be
+	// prefixed, reversed, blocked, segmented or alternated on their own
+	for _, name := range []string{"length-prefix", "reverse", "ecc", "qr-segments", "alt-alphabets"} {
+		if flagGiven(name) && !*decodeFlag && flag.NArg() > 1 {
+			return fmt.Errorf("-%s takes at most one input file when encoding", name)
+		}
+	}
+	if flagGiven("alphabet-per-file") && flag.NArg() == 0 {
+		return fmt.Errorf("-alphabet-per-file requires input files")
+	}
+	for _, f := range []struct {
+		name  string
+		value int64
+	}{
+		{"max-line-bytes", int64(*maxLineFlag)},
+		{"start-byte", *startFlag},
+		{"length", *windowFlag},
+		{"strict-length", *recordFlag},
+		{"lines", int64(*linesFlag)},
+		{"qr-segments", int64(*qrFlag)},
+	} {
+		if flagGiven(f.name) && f.value < 0 {
+			return fmt.Errorf("-%s must not be negative", f.name)
+		}
+	}
+	if flagGiven("expect") && *expectFlag != "utf8" {
+		return fmt.Errorf("invalid -expect %q (want utf8)", *expectFlag)
+	}
+	if flagGiven("go-literal") {
+		if err := checkGoName(*goFlag); err != nil {
+			return fmt.Errorf("invalid -go-literal: %v", err)
+		}
+	}
+	if flagGiven("max-memory") {
+		if _, err := parseSize(*memoryFlag); err != nil {
+			return fmt.Errorf("invalid -max-memory: %v", err)
+		}
+	}
+	if flagGiven("rotate") {
+		if _, err := parseRotate(*rotateFlag); err != nil {
+			return fmt.Errorf("invalid -rotate: %v", err)
+		}
+	}
+	return nil
+}
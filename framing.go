@@ -0,0 +1,107 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+)
+
+// messageTemplate is the default -out-template. Its verb is replaced by
+// the message number, starting at 1.
+const messageTemplate = "message-%04d.bin"
+
+// templateVerb matches a printf verb; %% is matched so it can be skipped.
+var templateVerb = regexp.MustCompile(`%[-+# 0]*[0-9]*(\.[0-9]+)?[a-zA-Z%]`)
+
+// checkTemplate makes sure a message file template has exactly one integer
+// verb, so every message gets its own name.
+func checkTemplate(template string) error {
+	numeric := 0
+	for _, verb := range templateVerb.FindAllString(template, -1) {
+		switch verb[len(verb)-1] {
+		case '%':
+		case 'd', 'x', 'X', 'o', 'b':
+			numeric++
+		default:
+			return fmt.Errorf("unsupported verb %s in %q", verb, template)
+		}
+	}
+	if numeric != 1 {
+		return fmt.Errorf("%q must contain exactly one numeric verb such as %%04d", template)
+	}
+	return nil
+}
+
+// messageFiles writes each decoded message to a file of its own. A message
+// ends at every delimiter, so back-to-back delimiters produce empty files.
+// Data after the last delimiter is kept as a final message, but since the
+// stream ended before the message was closed a warning is printed.
+type messageFiles struct {
+	template string
+	count    int
+	file     *os.File
+}
+
+func (m *messageFiles) Write(p []byte) (int, error) {
+	if m.file == nil {
+		if err := m.open(); err != nil {
+			return 0, err
+		}
+	}
+	return m.file.Write(p)
+}
+
+func (m *messageFiles) open() error {
+	m.count++
+	f, err := os.Create(fmt.Sprintf(m.template, m.count))
+	if err != nil {
+		return err
+	}
+	m.file = f
+	return nil
+}
+
+// end closes the current message, creating an empty file if nothing was
+// written to it.
+func (m *messageFiles) end() error {
+	if m.file == nil {
+		if err := m.open(); err != nil {
+			return err
+		}
+	}
+	err := m.file.Close()
+	m.file = nil
+	return err
+}
+
+// close finishes the last message at the end of the stream.
+func (m *messageFiles) close() error {
+	if m.file == nil {
+		return nil
+	}
+	if !*quietFlag {
+		fmt.Fprintf(os.Stderr, "Warning: input ended inside message %d\n", m.count)
+	}
+	return m.end()
+}
+
+// lengthPrefixSize is the size of the -length-prefix header: the data
+// length as a big-endian uint64, encoded like any other bytes.
+const lengthPrefixSize = 8
+
+// withLengthPrefix reads all of r, since the length has to be known before
+// anything is encoded, and returns a reader yielding the prefix followed by
+// the data.
+func withLengthPrefix(r io.Reader) (*bufio.Reader, error) {
+	data, err := readAll(r)
+	if err != nil {
+		return nil, err
+	}
+	prefix := make([]byte, lengthPrefixSize)
+	binary.BigEndian.PutUint64(prefix, uint64(len(data)))
+	return bufio.NewReader(io.MultiReader(bytes.NewReader(prefix), bytes.NewReader(data))), nil
+}
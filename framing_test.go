@@ -0,0 +1,55 @@
+package main
+
+import (
+	"bytes"
+	"io"
+	"testing"
+)
+
+func TestLengthPrefix(t *testing.T) {
+	encodeMap, decodeMap, err := createMaps(defaultAlphabet)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name    string
+		data    string
+		edit    func(encoded []rune) []rune
+		want    string
+		wantErr bool
+	}{
+		{"empty", "", nil, "", false},
+		{"data", "framed", nil, "framed", false},
+		{"trailing garbage ignored", "framed", func(e []rune) []rune {
+			return append(e, encodeMap[5], encodeMap[1])
+		}, "framed", false},
+		{"data cut short", "framed", func(e []rune) []rune { return e[:len(e)-2] }, "frame", true},
+		{"prefix cut short", "framed", func(e []rune) []rune { return e[:2*lengthPrefixSize-2] }, "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r, err := withLengthPrefix(bytes.NewReader([]byte(tt.data)))
+			if err != nil {
+				t.Fatal(err)
+			}
+			framed, err := io.ReadAll(r)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(framed) != lengthPrefixSize+len(tt.data) {
+				t.Fatalf("%d framed bytes for %d data bytes", len(framed), len(tt.data))
+			}
+			encoded := []rune(encodeString(t, framed, encodeMap, encodeOptions{spb: 2}))
+			if tt.edit != nil {
+				encoded = tt.edit(encoded)
+			}
+			got, err := decodeString(string(encoded), decodeMap, decodeOptions{overflow: "error", spb: 2, lengthPrefix: true})
+			if tt.wantErr != (err != nil) {
+				t.Fatalf("error %v, want error %v", err, tt.wantErr)
+			}
+			if string(got) != tt.want {
+				t.Errorf("decoded %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
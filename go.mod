@@ -0,0 +1,3 @@
+module github.com/706f6c6c7578/Code30
+
+go 1.23
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"time"
+)
+
+// chunk is one read from the input, passed from the reading goroutine.
+type chunk struct {
+	data []byte
+	err  error
+}
+
+// readBursts splits r into records at pauses, for -idle. A read blocks
+// until data arrives, so reading happens in a goroutine and the pause is
+// timed here; record gets each burst once nothing more came for idle, and
+// the rest of the input at its end. The goroutine reads into a single
+// buffer of bufferSize bytes; the burst collected so far counts against
+// -max-memory. Once the time budget has run out, the burst in progress is
+// dropped and readBursts returns.
+func readBursts(r io.Reader, idle time.Duration, record func([]byte) error) error {
+	chunks := make(chan chunk)
+	next := make(chan struct{})
+	stop := make(chan struct{})
+	defer close(stop)
+	go func() {
+		buf := make([]byte, bufferSize)
+		for {
+			n, err := r.Read(buf)
+			select {
+			case chunks <- chunk{buf[:n], err}:
+			case <-stop:
+				return
+			}
+			if err != nil {
+				return
+			}
+			// Wait until the data was copied before reusing buf
+			select {
+			case <-next:
+			case <-stop:
+				return
+			}
+		}
+	}()
+
+	var pending []byte
+	timer := time.NewTimer(idle)
+	timer.Stop()
+	for {
+		select {
+		case c := <-chunks:
+			pending = append(pending, c.data...)
+			if c.err != nil {
+				if len(pending) > 0 {
+					if err := record(pending); err != nil {
+						return err
+					}
+				}
+				if c.err == io.EOF {
+					return nil
+				}
+				return c.err
+			}
+			if wholeInputLimit > 0 && int64(len(pending)) > wholeInputLimit {
+				return fmt.Errorf("burst exceeds the %d bytes left by -max-memory", wholeInputLimit)
+			}
+			next <- struct{}{}
+			if len(c.data) > 0 {
+				timer.Reset(idle)
+			}
+		case <-timer.C:
+			if err := record(pending); err != nil {
+				return err
+			}
+			pending = pending[:0]
+		case <-budgetDone:
+			return nil
+		}
+	}
+}
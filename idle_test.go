@@ -0,0 +1,122 @@
+package main
+
+import (
+	"errors"
+	"io"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestReadBursts(t *testing.T) {
+	const idle = 50 * time.Millisecond
+	tests := []struct {
+		name   string
+		writes []string // "" pauses for twice idle
+		want   []string
+	}{
+		{"one burst", []string{"ab", "cd"}, []string{"abcd"}},
+		{"two bursts", []string{"ab", "", "cd"}, []string{"ab", "cd"}},
+		{"pause at the end", []string{"ab", ""}, []string{"ab"}},
+		{"pause at the start", []string{"", "ab", "", "c", "d"}, []string{"ab", "cd"}},
+		{"no input", nil, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r, w := io.Pipe()
+			go func() {
+				for _, s := range tt.writes {
+					if s == "" {
+						time.Sleep(2 * idle)
+						continue
+					}
+					w.Write([]byte(s))
+				}
+				w.Close()
+			}()
+
+			var got []string
+			err := readBursts(r, idle, func(record []byte) error {
+				got = append(got, string(record))
+				return nil
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("records %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestReadBurstsError(t *testing.T) {
+	failed := errors.New("read failed")
+	r, w := io.Pipe()
+	go func() {
+		w.Write([]byte("ab"))
+		w.CloseWithError(failed)
+	}()
+	var got []string
+	err := readBursts(r, time.Second, func(record []byte) error {
+		got = append(got, string(record))
+		return nil
+	})
+	if err != failed {
+		t.Errorf("error %v, want %v", err, failed)
+	}
+	if !reflect.DeepEqual(got, []string{"ab"}) {
+		t.Errorf("records %q, want the data read before the error", got)
+	}
+}
+
+// Once the budget runs out, readBursts returns instead of waiting for
+// more bursts.
+func TestReadBurstsBudget(t *testing.T) {
+	resetBudget(t)
+	r, w := io.Pipe()
+	defer w.Close()
+	go func() {
+		w.Write([]byte("ab"))
+		time.Sleep(100 * time.Millisecond)
+		w.Write([]byte("cd"))
+	}()
+
+	var got []string
+	done := make(chan error, 1)
+	go func() {
+		done <- readBursts(r, 20*time.Millisecond, func(record []byte) error {
+			got = append(got, string(record))
+			return nil
+		})
+	}()
+	startBudget(50 * time.Millisecond)
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("still reading 5s after a budget of 50ms")
+	}
+	if !reflect.DeepEqual(got, []string{"ab"}) {
+		t.Errorf("records %q, want only the one before the budget ran out", got)
+	}
+}
+
+func TestReadBurstsMemory(t *testing.T) {
+	saved := wholeInputLimit
+	t.Cleanup(func() { wholeInputLimit = saved })
+	wholeInputLimit = 10
+
+	r, w := io.Pipe()
+	defer w.Close()
+	go func() {
+		w.Write([]byte("0123456789"))
+		w.Write([]byte("x"))
+	}()
+	err := readBursts(r, time.Second, func([]byte) error { return nil })
+	if err == nil {
+		t.Error("an 11-byte burst fit in 10 bytes")
+	}
+}
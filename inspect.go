@@ -0,0 +1,75 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"unicode"
+)
+
+// inspectInput scans encoded input like decode does, without stopping at
+// problems, and writes a report about it instead of the decoded bytes.
+func inspectInput(reader *bufio.Reader, writer *bufio.Writer, decodeMap map[rune]byte, opts decodeOptions) error {
+	// Long lines are reported on, not an error
+	opts.maxLine = 0
+	symbols := newSymbolReader(reader, decodeMap, opts)
+
+	var data, whitespace, invalid, overflow int
+
+	// Bytes between the runes returned by symbols were skipped
+	skipped, end := 0, 0
+	firstInvalid := -1
+	value, digits, scale := 0, 0, 1
+	for {
+		r, offset, err := symbols.next()
+		skipped += offset - end
+		end = symbols.offset
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return fmt.Errorf("error reading input: %w", err)
+		}
+		digit, ok := decodeMap[r]
+		switch {
+		case ok:
+			data++
+		case unicode.IsSpace(r):
+			whitespace++
+			continue
+		default:
+			invalid++
+			if firstInvalid < 0 {
+				firstInvalid = offset
+			}
+			continue
+		}
+
+		// Digits come least significant first
+		value += int(digit) * scale
+		scale *= 30
+		if digits++; digits == opts.spb {
+			if value > 255 {
+				overflow++
+			}
+			value, digits, scale = 0, 0, 1
+		}
+	}
+
+	fmt.Fprintf(writer, "Data symbols:     %d\n", data)
+	fmt.Fprintf(writer, "Decoded bytes:    %d\n", data/opts.spb)
+	fmt.Fprintf(writer, "Skipped bytes:    %d (line breaks, separators, markers and comments)\n", skipped)
+	fmt.Fprintf(writer, "Whitespace:       %d\n", whitespace)
+	if invalid > 0 {
+		fmt.Fprintf(writer, "Invalid runes:    %d (first at offset %d)\n", invalid, firstInvalid)
+	} else {
+		fmt.Fprintf(writer, "Invalid runes:    0\n")
+	}
+	fmt.Fprintf(writer, "Groups above 255: %d\n", overflow)
+	if data%opts.spb == 0 {
+		fmt.Fprintf(writer, "Length:           clean, a multiple of %d\n", opts.spb)
+	} else {
+		fmt.Fprintf(writer, "Length:           %d trailing symbols do not form a group of %d\n", data%opts.spb, opts.spb)
+	}
+	return nil
+}
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"math/big"
+	"strings"
+)
+
+// encodeInt encodes the minimal big-endian bytes of a non-negative
+// decimal integer; zero is a single zero byte.
+func encodeInt(decimal string, encodeMap map[byte]rune, spb int) (string, error) {
+	n, ok := new(big.Int).SetString(decimal, 10)
+	if !ok {
+		return "", fmt.Errorf("invalid integer %q", decimal)
+	}
+	if n.Sign() < 0 {
+		return "", fmt.Errorf("negative integer %s", decimal)
+	}
+	data := n.Bytes()
+	if len(data) == 0 {
+		data = []byte{0}
+	}
+
+	var out bytes.Buffer
+	w := bufio.NewWriter(&out)
+	if err := encode(bufio.NewReader(bytes.NewReader(data)), w, encodeMap, encodeOptions{spb: spb}); err != nil {
+		return "", err
+	}
+	if err := w.Flush(); err != nil {
+		return "", err
+	}
+	return out.String(), nil
+}
+
+// decodeInt reverses encodeInt and returns the integer in decimal.
+func decodeInt(encoded string, decodeMap map[rune]byte, spb int) (string, error) {
+	var out bytes.Buffer
+	w := bufio.NewWriter(&out)
+	opts := decodeOptions{overflow: "error", spb: spb, quiet: true}
+	if err := decode(bufio.NewReader(strings.NewReader(encoded)), w, decodeMap, opts); err != nil {
+		return "", err
+	}
+	if err := w.Flush(); err != nil {
+		return "", err
+	}
+	if out.Len() == 0 {
+		return "", fmt.Errorf("empty input")
+	}
+	return new(big.Int).SetBytes(out.Bytes()).String(), nil
+}
@@ -0,0 +1,37 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+)
+
+// encodeLines encodes the whole input and spreads it over exactly n lines,
+// for -lines. Lines hold whole symbol groups; the first lines take one
+// group more than the rest when the groups do not divide evenly.
+func encodeLines(reader *bufio.Reader, writer *bufio.Writer, encodeMap map[byte]rune, n int, opts encodeOptions) error {
+	symbols, err := encodeWhole(reader, encodeMap, opts)
+	if err != nil {
+		return err
+	}
+	groups := len(symbols) / opts.spb
+	if groups < n {
+		return fmt.Errorf("%d bytes cannot fill %d lines", groups, n)
+	}
+	start := 0
+	for i := 0; i < n; i++ {
+		size := groups / n
+		if i < groups%n {
+			size++
+		}
+		end := start + size*opts.spb
+		line := string(symbols[start:end])
+		if i < n-1 || opts.finalEOL {
+			line += opts.eol
+		}
+		if _, err := writer.WriteString(line); err != nil {
+			return fmt.Errorf("error writing output: %w", err)
+		}
+		start = end
+	}
+	return nil
+}
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestEncodeLines(t *testing.T) {
+	encodeMap, decodeMap, err := createMaps(defaultAlphabet)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		size    int
+		lines   int
+		spb     int
+		wantErr bool
+	}{
+		{1, 1, 2, false},
+		{10, 3, 2, false},
+		{100, 7, 3, false},
+		{12, 12, 2, false},
+		{3, 4, 2, true},
+		{0, 1, 2, true},
+	}
+	for _, tt := range tests {
+		data := bytes.Repeat([]byte{200}, tt.size)
+		var out bytes.Buffer
+		w := bufio.NewWriter(&out)
+		err := encodeLines(bufio.NewReader(bytes.NewReader(data)), w, encodeMap, tt.lines, encodeOptions{spb: tt.spb, eol: "\n", finalEOL: true})
+		w.Flush()
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("%d bytes on %d lines: no error", tt.size, tt.lines)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		lines := strings.Split(strings.TrimSuffix(out.String(), "\n"), "\n")
+		if len(lines) != tt.lines {
+			t.Errorf("%d bytes: %d lines, want %d", tt.size, len(lines), tt.lines)
+		}
+		shortest, longest := tt.size*tt.spb, 0
+		for _, line := range lines {
+			n := len([]rune(line))
+			if n%tt.spb != 0 {
+				t.Errorf("line %q splits a symbol group", line)
+			}
+			shortest, longest = min(shortest, n), max(longest, n)
+		}
+		if longest-shortest > tt.spb {
+			t.Errorf("%d bytes on %d lines: lines of %d to %d symbols", tt.size, tt.lines, shortest, longest)
+		}
+		decoded, err := decodeString(out.String(), decodeMap, decodeOptions{overflow: "error", spb: tt.spb, quiet: true})
+		if err != nil || !bytes.Equal(decoded, data) {
+			t.Errorf("%d bytes on %d lines decode as %v, %v", tt.size, tt.lines, decoded, err)
+		}
+	}
+}
+
+// -lines holds the input and its encoding, and must stay within
+// -max-memory.
+func TestEncodeLinesMemory(t *testing.T) {
+	saved := wholeInputLimit
+	t.Cleanup(func() { wholeInputLimit = saved })
+	encodeMap, _, err := createMaps(defaultAlphabet)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data := make([]byte, 1000)
+
+	tests := []struct {
+		limit   int64
+		wantErr bool
+	}{
+		// 1000 input bytes, 2000 symbols of up to 3 bytes, 2000 runes
+		{1000 + 2000*3 + 2000*4, false},
+		{1000 + 2000*3 + 2000*4 - 1, true},
+		{1500, true},
+	}
+	for _, tt := range tests {
+		wholeInputLimit = tt.limit
+		w := bufio.NewWriter(&bytes.Buffer{})
+		err := encodeLines(bufio.NewReader(bytes.NewReader(data)), w, encodeMap, 2, encodeOptions{spb: 2, eol: "\n"})
+		if (err != nil) != tt.wantErr {
+			t.Errorf("limit %d: error %v, want error %v", tt.limit, err, tt.wantErr)
+		}
+	}
+}
@@ -0,0 +1,123 @@
+package main
+
+import (
+	"fmt"
+	"go/token"
+	"io"
+	"strconv"
+	"unicode/utf8"
+)
+
+// goStringWriter wraps the encoded output as a Go constant declaration,
+// `const name = "..."`, for -go-literal. Runes are escaped as strconv.Quote
+// does; a rune split between writes is held back until it is complete.
+type goStringWriter struct {
+	w       io.Writer
+	name    string
+	started bool
+	partial []byte
+}
+
+func (g *goStringWriter) start() error {
+	if g.started {
+		return nil
+	}
+	g.started = true
+	_, err := fmt.Fprintf(g.w, "const %s = \"", g.name)
+	return err
+}
+
+func (g *goStringWriter) Write(p []byte) (int, error) {
+	if err := g.start(); err != nil {
+		return 0, err
+	}
+	data := append(g.partial, p...)
+	end := len(data)
+	for cut := end - 1; cut >= 0 && cut >= end-utf8.UTFMax; cut-- {
+		if utf8.RuneStart(data[cut]) {
+			if !utf8.FullRune(data[cut:]) {
+				end = cut
+			}
+			break
+		}
+	}
+	quoted := strconv.Quote(string(data[:end]))
+	if _, err := io.WriteString(g.w, quoted[1:len(quoted)-1]); err != nil {
+		return 0, err
+	}
+	g.partial = append([]byte(nil), data[end:]...)
+	return len(p), nil
+}
+
+// Close ends the declaration; it does not close the underlying writer.
+func (g *goStringWriter) Close() error {
+	if err := g.start(); err != nil {
+		return err
+	}
+	quoted := strconv.Quote(string(g.partial))
+	_, err := io.WriteString(g.w, quoted[1:len(quoted)-1]+"\"\n")
+	return err
+}
+
+// goBytesPerLine is the number of values on each line of a goBytesWriter.
+const goBytesPerLine = 12
+
+// goBytesWriter wraps the decoded bytes as a Go variable declaration,
+// `var name = []byte{...}`, for -go-literal.
+type goBytesWriter struct {
+	w       io.Writer
+	name    string
+	started bool
+	n       int64
+	buf     []byte
+}
+
+func (g *goBytesWriter) start() error {
+	if g.started {
+		return nil
+	}
+	g.started = true
+	_, err := fmt.Fprintf(g.w, "var %s = []byte{", g.name)
+	return err
+}
+
+func (g *goBytesWriter) Write(p []byte) (int, error) {
+	if err := g.start(); err != nil {
+		return 0, err
+	}
+	g.buf = g.buf[:0]
+	for _, v := range p {
+		if g.n%goBytesPerLine == 0 {
+			g.buf = append(g.buf, "\n\t"...)
+		} else {
+			g.buf = append(g.buf, ' ')
+		}
+		g.buf = fmt.Appendf(g.buf, "0x%02x,", v)
+		g.n++
+	}
+	if _, err := g.w.Write(g.buf); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// Close ends the declaration; it does not close the underlying writer.
+func (g *goBytesWriter) Close() error {
+	if err := g.start(); err != nil {
+		return err
+	}
+	end := "}\n"
+	if g.n > 0 {
+		end = "\n}\n"
+	}
+	_, err := io.WriteString(g.w, end)
+	return err
+}
+
+// checkGoName makes sure a -go-literal name is a usable Go identifier.
+func checkGoName(name string) error {
+	if !token.IsIdentifier(name) {
+		return fmt.Errorf("%q is not a Go identifier", name)
+	}
+	return nil
+}
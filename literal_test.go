@@ -0,0 +1,125 @@
+package main
+
+import (
+	"bytes"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"strconv"
+	"testing"
+)
+
+// parseDecl parses the declaration written by a -go-literal writer and
+// returns the expression it assigns to name.
+func parseDecl(t *testing.T, src, name string) ast.Expr {
+	t.Helper()
+	file, err := parser.ParseFile(token.NewFileSet(), "", "package p\n"+src, 0)
+	if err != nil {
+		t.Fatalf("%v in\n%s", err, src)
+	}
+	spec := file.Decls[0].(*ast.GenDecl).Specs[0].(*ast.ValueSpec)
+	if spec.Names[0].Name != name {
+		t.Errorf("declares %s, want %s", spec.Names[0].Name, name)
+	}
+	return spec.Values[0]
+}
+
+func TestGoStringWriter(t *testing.T) {
+	tests := []struct {
+		name   string
+		chunks []string // as written, possibly splitting runes
+	}{
+		{"nothing", nil},
+		{"empty write", []string{""}},
+		{"symbols", []string{"ABCÄÖÜẞ"}},
+		{"rune split between writes", []string{"A\xc3", "\x84B\xe1\xba", "\x9e"}},
+		{"quotes and escapes", []string{"\"\\\r\n\t~"}},
+		{"invalid utf-8", []string{"\xff\xc3"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var out bytes.Buffer
+			g := &goStringWriter{w: &out, name: "encoded"}
+			var want string
+			for _, chunk := range tt.chunks {
+				if _, err := g.Write([]byte(chunk)); err != nil {
+					t.Fatal(err)
+				}
+				want += chunk
+			}
+			if err := g.Close(); err != nil {
+				t.Fatal(err)
+			}
+			lit, ok := parseDecl(t, out.String(), "encoded").(*ast.BasicLit)
+			if !ok || lit.Kind != token.STRING {
+				t.Fatalf("not a string constant:\n%s", out.String())
+			}
+			got, err := strconv.Unquote(lit.Value)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != want {
+				t.Errorf("constant holds %q, want %q", got, want)
+			}
+		})
+	}
+}
+
+func TestGoBytesWriter(t *testing.T) {
+	tests := []struct {
+		name   string
+		chunks [][]byte
+	}{
+		{"nothing", nil},
+		{"one byte", [][]byte{{0}}},
+		{"exactly one line", [][]byte{bytes.Repeat([]byte{0xff}, goBytesPerLine)}},
+		{"lines across writes", [][]byte{[]byte("decoded "), []byte("bytes over"), []byte(" several lines")}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var out bytes.Buffer
+			g := &goBytesWriter{w: &out, name: "decoded"}
+			var want []byte
+			for _, chunk := range tt.chunks {
+				if _, err := g.Write(chunk); err != nil {
+					t.Fatal(err)
+				}
+				want = append(want, chunk...)
+			}
+			if err := g.Close(); err != nil {
+				t.Fatal(err)
+			}
+			lit, ok := parseDecl(t, out.String(), "decoded").(*ast.CompositeLit)
+			if !ok {
+				t.Fatalf("not a composite literal:\n%s", out.String())
+			}
+			var got []byte
+			for _, elt := range lit.Elts {
+				v, err := strconv.ParseUint(elt.(*ast.BasicLit).Value, 0, 8)
+				if err != nil {
+					t.Fatal(err)
+				}
+				got = append(got, byte(v))
+			}
+			if !bytes.Equal(got, want) {
+				t.Errorf("slice holds %v, want %v", got, want)
+			}
+		})
+	}
+}
+
+func TestCheckGoName(t *testing.T) {
+	for name, ok := range map[string]bool{
+		"encoded": true,
+		"_x9":     true,
+		"Ärger":   true,
+		"":        false,
+		"9lives":  false,
+		"a-b":     false,
+		"func":    false,
+	} {
+		if err := checkGoName(name); (err == nil) != ok {
+			t.Errorf("checkGoName(%q) = %v", name, err)
+		}
+	}
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+)
+
+// manifestEntry is one line of a -manifest file. Start and End are the
+// range of decoded bytes the part holds, so decoding the parts in order
+// and concatenating them reproduces the whole; Size and SHA256 describe
+// the encoded file itself.
+type manifestEntry struct {
+	Part   int    `json:"part"`
+	Name   string `json:"name"`
+	Start  int64  `json:"start"`
+	End    int64  `json:"end"`
+	Size   int64  `json:"size"`
+	SHA256 string `json:"sha256"`
+}
+
+// writeManifest describes the finished output files as JSON lines in path.
+// Each part is decoded again to learn its range, which also checks that it
+// decodes on its own.
+func writeManifest(path string, names []string, decodeMap map[rune]byte, opts decodeOptions) error {
+	opts.lengthPrefix, opts.offsetMap, opts.quiet = false, nil, true
+
+	out, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	enc := json.NewEncoder(out)
+	var offset int64
+	for i, name := range names {
+		entry, err := manifestPart(name, decodeMap, opts)
+		if err != nil {
+			out.Close()
+			return err
+		}
+		entry.Part = i + 1
+		entry.Start, entry.End = offset, offset+entry.End
+		offset = entry.End
+		if err := enc.Encode(entry); err != nil {
+			out.Close()
+			return err
+		}
+	}
+	return out.Close()
+}
+
+// manifestPart hashes and decodes one output file. The decoded length is
+// returned in End.
+func manifestPart(name string, decodeMap map[rune]byte, opts decodeOptions) (manifestEntry, error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return manifestEntry{}, err
+	}
+	defer f.Close()
+
+	hash := sha256.New()
+	counter := &countingWriter{w: io.Discard}
+	decoded := bufio.NewWriter(counter)
+	if err := decode(bufio.NewReader(io.TeeReader(f, hash)), decoded, decodeMap, opts); err != nil {
+		return manifestEntry{}, fmt.Errorf("%s: %w", name, err)
+	}
+	if err := decoded.Flush(); err != nil {
+		return manifestEntry{}, err
+	}
+	info, err := f.Stat()
+	if err != nil {
+		return manifestEntry{}, err
+	}
+	return manifestEntry{
+		Name:   name,
+		End:    counter.n,
+		Size:   info.Size(),
+		SHA256: hex.EncodeToString(hash.Sum(nil)),
+	}, nil
+}
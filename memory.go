@@ -0,0 +1,118 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// The -max-memory budget covers the allocations that grow with the
+// configuration rather than with the Go runtime itself:
+//
+//   - every bufio buffer: the input reader, each output layer and the
+//     index writer, bufferSize bytes each (the index writer is smaller,
+//     but is counted at full size), the read buffer of -idle and the
+//     writer holding back output for -continue-on-error;
+//   - the encode line buffer: four bytes per symbol of a line, or per
+//     symbol of an unwrapped chunk, twice because lines are converted to
+//     strings before writing;
+//   - whatever remains is the cap for modes that hold the whole input in
+//     memory, such as -buffered, -length-prefix and -parallel-decode, or
+//     its encoding, such as -lines (see encodeWhole), for the distinct
+//     blocks -dedup-block keeps and the bursts -idle collects, and for
+//     the output of one file held back by -continue-on-error.
+//
+// wholeInputLimit is that remainder, or 0 when there is no budget.
+var wholeInputLimit int64
+
+// setMemoryLimit checks the fixed allocations against limit and sets
+// wholeInputLimit to what is left.
+func setMemoryLimit(limit int64, buffers, lineRunes int) error {
+	fixed := int64(buffers)*bufferSize + 2*4*int64(lineRunes)
+	if fixed >= limit {
+		return fmt.Errorf("-max-memory %d is too small: buffers need %d bytes", limit, fixed)
+	}
+	wholeInputLimit = limit - fixed
+	return nil
+}
+
+// readAll reads r to the end, failing once the input exceeds
+// wholeInputLimit.
+func readAll(r io.Reader) ([]byte, error) {
+	if wholeInputLimit == 0 {
+		data, err := io.ReadAll(r)
+		if err != nil {
+			return nil, fmt.Errorf("error reading input: %w", err)
+		}
+		return data, nil
+	}
+
+	data, err := io.ReadAll(io.LimitReader(r, wholeInputLimit+1))
+	if err != nil {
+		return nil, fmt.Errorf("error reading input: %w", err)
+	}
+	if int64(len(data)) > wholeInputLimit {
+		return nil, fmt.Errorf("input exceeds the %d bytes left by -max-memory", wholeInputLimit)
+	}
+	return data, nil
+}
+
+// encodeWhole encodes the whole input without wrapping and returns the
+// symbols, for modes that can only lay out the output once it is complete.
+// The input, the encoded text and its runes all count against
+// wholeInputLimit.
+func encodeWhole(reader *bufio.Reader, encodeMap map[byte]rune, opts encodeOptions) ([]rune, error) {
+	data, err := readAll(reader)
+	if err != nil {
+		return nil, err
+	}
+	if wholeInputLimit > 0 {
+		symbolSize := 0
+		for _, m := range []map[byte]rune{encodeMap, opts.oddMap} {
+			for _, r := range m {
+				symbolSize = max(symbolSize, utf8.RuneLen(r))
+			}
+		}
+		symbols := int64(len(data)) * int64(opts.spb)
+		if need := int64(len(data)) + symbols*int64(symbolSize+4); need > wholeInputLimit {
+			return nil, fmt.Errorf("encoding the input in memory needs %d bytes, more than the %d left by -max-memory", need, wholeInputLimit)
+		}
+	}
+
+	var encoded bytes.Buffer
+	w := bufio.NewWriter(&encoded)
+	opts.width = 0
+	if err := encode(bufio.NewReader(bytes.NewReader(data)), w, encodeMap, opts); err != nil {
+		return nil, err
+	}
+	if err := w.Flush(); err != nil {
+		return nil, err
+	}
+	return bytes.Runes(encoded.Bytes()), nil
+}
+
+// parseSize parses a byte count with an optional K, M or G suffix
+// (powers of 1024).
+func parseSize(s string) (int64, error) {
+	multiplier := int64(1)
+	switch {
+	case strings.HasSuffix(s, "K"):
+		multiplier = 1 << 10
+	case strings.HasSuffix(s, "M"):
+		multiplier = 1 << 20
+	case strings.HasSuffix(s, "G"):
+		multiplier = 1 << 30
+	}
+	if multiplier > 1 {
+		s = s[:len(s)-1]
+	}
+	n, err := strconv.ParseInt(s, 10, 64)
+	if err != nil || n <= 0 {
+		return 0, fmt.Errorf("bad size %q", s)
+	}
+	return n * multiplier, nil
+}
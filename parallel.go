@@ -0,0 +1,99 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"runtime"
+	"sync"
+)
+
+// parallelWorkers is how many segments decodeParallel cuts its input into.
+var parallelWorkers = runtime.NumCPU()
+
+// decodeParallel decodes wrapped input by cutting it at line breaks, and
+// at the markers written by -block, into parallelWorkers segments, decoding
+// the segments concurrently. Lines written by encode -w and blocks always
+// hold whole symbol groups; for input wrapped some other way, a cut is
+// moved on to a later break until it falls between groups, so every
+// segment decodes on its own. Input without enough breaks is decoded
+// serially.
+func decodeParallel(data []byte, writer *bufio.Writer, decodeMap map[rune]byte, opts decodeOptions) error {
+	betweenGroups := func(segment []byte) bool {
+		symbols := 0
+		for _, r := range string(segment) {
+			if _, ok := decodeMap[r]; ok {
+				symbols++
+			}
+		}
+		return symbols%opts.spb == 0
+	}
+	breaks := "\r\n"
+	if _, ok := decodeMap[checkpointMarker]; !ok {
+		breaks += string(checkpointMarker)
+	}
+	segments := splitLines(data, parallelWorkers, breaks, betweenGroups)
+	if len(segments) < 2 {
+		return decode(bufio.NewReader(bytes.NewReader(data)), writer, decodeMap, opts)
+	}
+
+	opts.quiet = true
+	results := make([]bytes.Buffer, len(segments))
+	errs := make([]error, len(segments))
+	var wg sync.WaitGroup
+	for i, segment := range segments {
+		wg.Add(1)
+		go func(i int, segment []byte) {
+			defer wg.Done()
+			w := bufio.NewWriter(&results[i])
+			if errs[i] = decode(bufio.NewReader(bytes.NewReader(segment)), w, decodeMap, opts); errs[i] == nil {
+				errs[i] = w.Flush()
+			}
+		}(i, segment)
+	}
+	wg.Wait()
+
+	offset := 0
+	for i, segment := range segments {
+		if errs[i] != nil {
+			return fmt.Errorf("segment starting at offset %d: %w", offset, errs[i])
+		}
+		if _, err := writer.Write(results[i].Bytes()); err != nil {
+			return fmt.Errorf("error writing output: %w", err)
+		}
+		offset += len(segment)
+	}
+	return nil
+}
+
+// splitLines cuts data into at most n pieces of similar size, each ending
+// right after one of the breaks (such as LF or bare CR) except the last.
+// A piece is only cut off where ok accepts it.
+func splitLines(data []byte, n int, breaks string, ok func([]byte) bool) [][]byte {
+	var segments [][]byte
+	target := len(data)/n + 1
+	for len(data) > 0 {
+		cut := target
+		for cut < len(data) {
+			next := bytes.IndexAny(data[cut:], breaks)
+			if next < 0 {
+				cut = len(data)
+				break
+			}
+			cut += next + 1
+			if cut < len(data) && data[cut-1] == '\r' && data[cut] == '\n' {
+				cut++
+			}
+			if ok(data[:cut]) {
+				break
+			}
+		}
+		if cut >= len(data) {
+			segments = append(segments, data)
+			break
+		}
+		segments = append(segments, data[:cut])
+		data = data[cut:]
+	}
+	return segments
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestDecodeParallel(t *testing.T) {
+	saved := parallelWorkers
+	t.Cleanup(func() { parallelWorkers = saved })
+
+	encodeMap, decodeMap, err := createMaps(defaultAlphabet)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data := make([]byte, 5000)
+	for i := range data {
+		data[i] = byte(i*31 + i/7)
+	}
+	// Every 7 symbols, so most breaks fall inside a group
+	oddlyWrapped := func(text string) string {
+		var b strings.Builder
+		for i, r := range []rune(text) {
+			if i > 0 && i%7 == 0 {
+				b.WriteString("\n")
+			}
+			b.WriteRune(r)
+		}
+		return b.String()
+	}
+	tests := []struct {
+		name string
+		text string
+	}{
+		{"wrapped", encodeString(t, data, encodeMap, encodeOptions{spb: 2, width: 60, eol: "\r\n"})},
+		{"blocks", encodeString(t, data, encodeMap, encodeOptions{spb: 2, block: 300})},
+		{"wrapped inside groups", oddlyWrapped(encodeString(t, data, encodeMap, encodeOptions{spb: 2}))},
+	}
+	for _, tt := range tests {
+		for _, workers := range []int{1, 2, 7, 64} {
+			parallelWorkers = workers
+			all := func([]byte) bool { return true }
+			if segments := splitLines([]byte(tt.text), workers, "\r\n~", all); workers > 1 && len(segments) < 2 {
+				t.Fatalf("%s is not cut for %d workers", tt.name, workers)
+			}
+			want, err := decodeString(tt.text, decodeMap, decodeOptions{spb: 2})
+			if err != nil {
+				t.Fatal(err)
+			}
+			var got bytes.Buffer
+			w := bufio.NewWriter(&got)
+			if err := decodeParallel([]byte(tt.text), w, decodeMap, decodeOptions{spb: 2}); err != nil {
+				t.Fatalf("%s, %d workers: %v", tt.name, workers, err)
+			}
+			if err := w.Flush(); err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(got.Bytes(), want) || !bytes.Equal(want, data) {
+				t.Errorf("%s, %d workers: decoded %d bytes unlike a sequential decode", tt.name, workers, got.Len())
+			}
+		}
+	}
+}
@@ -0,0 +1,121 @@
+package main
+
+import (
+	"compress/gzip"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// pipelineStage is one byte transform of a -pipeline. Each stage has an
+// exact inverse: gunzip for gzip, and xor with the same key for xor.
+type pipelineStage struct {
+	name string // "gzip" or "xor"
+	key  []byte // xor key
+	pos  *int   // xor key position, carried from one input to the next
+}
+
+// parsePipeline parses a spec like "gzip,xor:KEY,encode". The stages are
+// applied in order before encoding; "encode" must come last. Decoding
+// runs the inverse stages in reverse order after the codec.
+func parsePipeline(spec string) ([]pipelineStage, error) {
+	parts := strings.Split(spec, ",")
+	if parts[len(parts)-1] != "encode" {
+		return nil, fmt.Errorf("%q must end with encode", spec)
+	}
+
+	var stages []pipelineStage
+	for _, part := range parts[:len(parts)-1] {
+		name, arg, hasArg := strings.Cut(part, ":")
+		switch {
+		case name == "gzip" && !hasArg:
+			stages = append(stages, pipelineStage{name: name})
+		case name == "xor" && arg != "":
+			stages = append(stages, pipelineStage{name: name, key: []byte(arg), pos: new(int)})
+		case name == "xor":
+			return nil, fmt.Errorf("xor needs a key, as in xor:KEY")
+		case name == "encode":
+			return nil, fmt.Errorf("encode may only appear once, at the end")
+		default:
+			return nil, fmt.Errorf("unknown stage %q", part)
+		}
+	}
+	return stages, nil
+}
+
+// pipelineReader applies the stages to r in order, for encoding.
+func pipelineReader(r io.Reader, stages []pipelineStage) io.Reader {
+	for _, stage := range stages {
+		switch stage.name {
+		case "gzip":
+			r = gzipReader(r)
+		case "xor":
+			r = &xorReader{r: r, key: stage.key, pos: stage.pos}
+		}
+	}
+	return r
+}
+
+// pushPipeline adds the inverse stages to the output chain, for decoding.
+// The last stage pushed is applied first, so pushing in forward order
+// undoes the stages in reverse.
+func pushPipeline(chain *outputChain, stages []pipelineStage) {
+	for _, stage := range stages {
+		switch stage.name {
+		case "gzip":
+			gunzip := newGunzipWriter(chain.top)
+			chain.push(gunzip, gunzip.Close)
+		case "xor":
+			chain.push(&xorWriter{w: chain.top, key: stage.key}, nil)
+		}
+	}
+}
+
+// gzipReader returns a reader yielding the gzip-compressed content of r.
+func gzipReader(r io.Reader) io.Reader {
+	pr, pw := io.Pipe()
+	go func() {
+		zw := gzip.NewWriter(pw)
+		_, err := io.Copy(zw, r)
+		if err == nil {
+			err = zw.Close()
+		}
+		pw.CloseWithError(err)
+	}()
+	return pr
+}
+
+// xorReader xors the bytes of r with a repeating key. The position in
+// the key is shared by the readers of all inputs, so that the xorWriter
+// undoing them sees one continuous stream.
+type xorReader struct {
+	r   io.Reader
+	key []byte
+	pos *int
+}
+
+func (x *xorReader) Read(p []byte) (int, error) {
+	n, err := x.r.Read(p)
+	for i := range p[:n] {
+		p[i] ^= x.key[*x.pos%len(x.key)]
+		*x.pos++
+	}
+	return n, err
+}
+
+// xorWriter xors everything written to it with a repeating key.
+type xorWriter struct {
+	w   io.Writer
+	key []byte
+	pos int
+	buf []byte
+}
+
+func (x *xorWriter) Write(p []byte) (int, error) {
+	x.buf = append(x.buf[:0], p...)
+	for i := range x.buf {
+		x.buf[i] ^= x.key[x.pos%len(x.key)]
+		x.pos++
+	}
+	return x.w.Write(x.buf)
+}
@@ -0,0 +1,113 @@
+package main
+
+import (
+	"bytes"
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestParsePipeline(t *testing.T) {
+	tests := []struct {
+		spec    string
+		want    []string // stage names
+		wantErr bool
+	}{
+		{"encode", nil, false},
+		{"gzip,encode", []string{"gzip"}, false},
+		{"xor:k,gzip,xor:other,encode", []string{"xor", "gzip", "xor"}, false},
+		{"gzip", nil, true},
+		{"encode,gzip", nil, true},
+		{"gzip,encode,encode", nil, true},
+		{"xor,encode", nil, true},
+		{"xor:,encode", nil, true},
+		{"gzip:9,encode", nil, true},
+		{"zip,encode", nil, true},
+		{",encode", nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.spec, func(t *testing.T) {
+			stages, err := parsePipeline(tt.spec)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("accepted, %d stages", len(stages))
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			var names []string
+			for _, stage := range stages {
+				names = append(names, stage.name)
+			}
+			if strings.Join(names, ",") != strings.Join(tt.want, ",") {
+				t.Errorf("stages %v, want %v", names, tt.want)
+			}
+		})
+	}
+}
+
+func TestPipelineRoundTrip(t *testing.T) {
+	encodeMap, decodeMap, err := createMaps(defaultAlphabet)
+	if err != nil {
+		t.Fatal(err)
+	}
+	inputs := []string{strings.Repeat("pipeline round trip ", 40), "", "second input"}
+	for _, spec := range []string{
+		"encode",
+		"gzip,encode",
+		"xor:key,encode",
+		"gzip,xor:key,encode",
+		"xor:key,gzip,encode",
+		"xor:a,xor:bcd,gzip,gzip,encode",
+	} {
+		t.Run(spec, func(t *testing.T) {
+			stages, err := parsePipeline(spec)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var out bytes.Buffer
+			chain := newOutputChain(&out)
+			pushPipeline(chain, stages)
+			for _, input := range inputs {
+				transformed, err := io.ReadAll(pipelineReader(strings.NewReader(input), stages))
+				if err != nil {
+					t.Fatal(err)
+				}
+				text := encodeString(t, transformed, encodeMap, encodeOptions{spb: 2})
+				decoded, err := decodeString(text, decodeMap, decodeOptions{spb: 2})
+				if err != nil {
+					t.Fatal(err)
+				}
+				if _, err := chain.top.Write(decoded); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if err := chain.close(); err != nil {
+				t.Fatal(err)
+			}
+			if want := strings.Join(inputs, ""); out.String() != want {
+				t.Errorf("round trip gave %q, want %q", out.String(), want)
+			}
+		})
+	}
+}
+
+func TestPipelineFlag(t *testing.T) {
+	input := strings.Repeat("through the command line ", 20)
+	encoded, stderr, code := runMain(t, "", input, "-q", "-pipeline", "gzip,xor:secret,encode")
+	if code != 0 {
+		t.Fatalf("encode: exit %d: %s", code, stderr)
+	}
+	decoded, stderr, code := runMain(t, "", encoded, "-q", "-d", "-pipeline", "gzip,xor:secret,encode")
+	if code != 0 {
+		t.Fatalf("decode: exit %d: %s", code, stderr)
+	}
+	if decoded != input {
+		t.Errorf("decoded %q, want %q", decoded, input)
+	}
+	if _, stderr, code := runMain(t, "", input, "-q", "-pipeline", "gzip,rot13,encode"); code == 0 || !strings.Contains(stderr, "rot13") {
+		t.Errorf("unknown stage: exit %d: %s", code, stderr)
+	}
+}
@@ -0,0 +1,102 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// preset is a named, vetted alphabet selectable with -preset.
+type preset struct {
+	alphabet    string
+	description string
+}
+
+var presets = map[string]preset{
+	"german": {
+		alphabet:    defaultAlphabet,
+		description: "German uppercase letters A-Z, ÄÖÜẞ (default)",
+	},
+	// Crockford's Base32 symbols without 0 and 1: digits 2-9 and the
+	// letters A-Z except I, L, O and U. Leaving out 0/O and 1/I/L removes
+	// the classic handwriting confusions, U avoids U/V, and there are no
+	// umlauts to hunt for on a foreign keyboard.
+	"transcribe": {
+		alphabet:    "23456789ABCDEFGHJKMNPQRSTVWXYZ",
+		description: "digits and letters that are hard to confuse when written or typed",
+	},
+}
+
+// lookupPreset returns the alphabet of the named preset.
+func lookupPreset(name string) (string, error) {
+	p, ok := presets[name]
+	if !ok {
+		names := make([]string, 0, len(presets))
+		for n := range presets {
+			names = append(names, n)
+		}
+		sort.Strings(names)
+		return "", fmt.Errorf("unknown preset %q (available: %s)", name, strings.Join(names, ", "))
+	}
+	return p.alphabet, nil
+}
+
+// listPresets writes one line per preset for -list-presets: name, base and
+// description separated by tabs, sorted by name, so that shell completion
+// can take the first field.
+func listPresets(w io.Writer) error {
+	names := make([]string, 0, len(presets))
+	for n := range presets {
+		names = append(names, n)
+	}
+	sort.Strings(names)
+	for _, n := range names {
+		if _, err := fmt.Fprintf(w, "%s\t%d\t%s\n", n, len([]rune(presets[n].alphabet)), presets[n].description); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// inferPreset picks the preset whose alphabet contains every symbol of the
+// encoded data, for -infer. Whitespace, comment lines and the checkpoint
+// and pad markers are not symbols. No match or several are errors, as the
+// data cannot tell the presets apart then.
+func inferPreset(data []byte) (string, error) {
+	seen := make(map[rune]bool)
+	for _, line := range bytes.Split(data, []byte("\n")) {
+		if len(line) > 0 && line[0] == commentMarker {
+			continue
+		}
+		for _, r := range string(line) {
+			if !unicode.IsSpace(r) && r != checkpointMarker && r != padRune {
+				seen[r] = true
+			}
+		}
+	}
+
+	var matches []string
+	for name, p := range presets {
+		contains := true
+		for r := range seen {
+			if !strings.ContainsRune(p.alphabet, r) {
+				contains = false
+				break
+			}
+		}
+		if contains {
+			matches = append(matches, name)
+		}
+	}
+	sort.Strings(matches)
+	switch len(matches) {
+	case 0:
+		return "", fmt.Errorf("no preset contains all %d symbols of the input; give -a", len(seen))
+	case 1:
+		return matches[0], nil
+	}
+	return "", fmt.Errorf("the input fits the presets %s; give -preset", strings.Join(matches, ", "))
+}
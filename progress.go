@@ -0,0 +1,132 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"time"
+)
+
+// now is the clock behind the timings and the progress rate. Tests can
+// replace it to get a fixed duration in the summary.
+var now = time.Now
+
+// progressShown holds the progress text currently visible on stderr, so it
+// can be wiped before an error message is printed.
+var progressShown string
+
+// stderrIsTerminal selects in-place progress updates; when stderr goes to a
+// file or pipe, each update is written as a line of its own instead.
+var stderrIsTerminal = isTerminal(os.Stderr)
+
+// isTerminal reports whether f is a character device such as a terminal,
+// other than the null device.
+func isTerminal(f *os.File) bool {
+	info, err := f.Stat()
+	if err != nil || info.Mode()&os.ModeCharDevice == 0 {
+		return false
+	}
+	null, err := os.Stat(os.DevNull)
+	return err != nil || !os.SameFile(info, null)
+}
+
+// -progress-bar state: the size of the current input, 0 when unknown, and
+// the last sample of the smoothed throughput in bytes per second.
+var (
+	progressTotal int64
+	sampleTime    time.Time
+	sampleBytes   int
+	sampleRate    float64
+)
+
+// barWidth is the number of cells of the -progress-bar bar.
+const barWidth = 20
+
+// startProgress prepares -progress-bar for the input f. The bar needs the
+// input size, so it is only shown for regular files.
+func startProgress(f *os.File) {
+	progressTotal = 0
+	if !*barFlag || !stderrIsTerminal {
+		return
+	}
+	if info, err := f.Stat(); err == nil && info.Mode().IsRegular() {
+		progressTotal = info.Size()
+	}
+	sampleTime, sampleBytes, sampleRate = now(), 0, 0
+}
+
+// progressBar renders a line like
+// "[##########----------]  50% 273/524 MB 170 MB/s ETA 0:01".
+func progressBar(totalBytes int) string {
+	current := now()
+	if elapsed := current.Sub(sampleTime).Seconds(); elapsed > 0 {
+		rate := float64(totalBytes-sampleBytes) / elapsed
+		if sampleRate == 0 {
+			sampleRate = rate
+		} else {
+			sampleRate = 0.7*sampleRate + 0.3*rate
+		}
+	}
+	sampleTime, sampleBytes = current, totalBytes
+
+	done := float64(totalBytes) / float64(progressTotal)
+	if done > 1 {
+		done = 1
+	}
+	filled := int(done * barWidth)
+	eta := 0
+	if sampleRate > 0 && int64(totalBytes) < progressTotal {
+		eta = int(float64(progressTotal-int64(totalBytes)) / sampleRate)
+	}
+	return fmt.Sprintf("[%s%s] %3.0f%% %d/%d MB %.0f MB/s ETA %d:%02d",
+		strings.Repeat("#", filled), strings.Repeat("-", barWidth-filled), done*100,
+		totalBytes/1024/1024, progressTotal/1024/1024, sampleRate/1024/1024, eta/60, eta%60)
+}
+
+// reportProgress prints the running total whenever another megabyte has
+// been processed.
+func reportProgress(totalBytes int) {
+	if *quietFlag || totalBytes%bufferSize != 0 {
+		return
+	}
+	text := fmt.Sprintf("Processed: %d MB", totalBytes/1024/1024)
+	if progressTotal > 0 {
+		text = progressBar(totalBytes)
+	}
+	if !stderrIsTerminal {
+		fmt.Fprintln(os.Stderr, text)
+		return
+	}
+	progressShown = text
+	fmt.Fprintf(os.Stderr, "\r%s", progressShown)
+}
+
+// completionSummary is the line printed once all input is processed.
+func completionSummary(d time.Duration) string {
+	return fmt.Sprintf("Operation completed in %v", d)
+}
+
+// endProgress terminates the progress line.
+func endProgress() {
+	progressShown = ""
+	if *quietFlag || !stderrIsTerminal {
+		return
+	}
+	fmt.Fprint(os.Stderr, "\n")
+}
+
+// clearProgress blanks out an unfinished progress line.
+func clearProgress() {
+	if progressShown == "" {
+		return
+	}
+	fmt.Fprintf(os.Stderr, "\r%s\r", strings.Repeat(" ", len(progressShown)))
+	progressShown = ""
+}
+
+// printError prints an error message, making sure no stale progress text
+// is left in front of it.
+func printError(format string, a ...interface{}) {
+	clearProgress()
+	fmt.Fprintf(os.Stderr, "\n"+format+"\n", a...)
+}
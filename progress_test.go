@@ -0,0 +1,66 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+// fakeClock makes now return the given times in turn.
+func fakeClock(t *testing.T, times ...time.Time) {
+	t.Helper()
+	saved := now
+	t.Cleanup(func() { now = saved })
+	now = func() time.Time {
+		if len(times) == 0 {
+			t.Fatal("clock read more often than expected")
+		}
+		current := times[0]
+		times = times[1:]
+		return current
+	}
+}
+
+func TestCompletionSummary(t *testing.T) {
+	start := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		elapsed time.Duration
+		want    string
+	}{
+		{0, "Operation completed in 0s"},
+		{1500 * time.Millisecond, "Operation completed in 1.5s"},
+		{2*time.Minute + 3*time.Second, "Operation completed in 2m3s"},
+	}
+	for _, tt := range tests {
+		fakeClock(t, start, start.Add(tt.elapsed))
+		begin := now()
+		if got := completionSummary(now().Sub(begin)); got != tt.want {
+			t.Errorf("got %q, want %q", got, tt.want)
+		}
+	}
+}
+
+func TestProgressBar(t *testing.T) {
+	const mb = 1024 * 1024
+	start := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	fakeClock(t, start.Add(time.Second), start.Add(2*time.Second), start.Add(2*time.Second))
+	progressTotal = 100 * mb
+	sampleTime, sampleBytes, sampleRate = start, 0, 0
+	t.Cleanup(func() { progressTotal = 0 })
+
+	tests := []struct {
+		total int
+		want  string
+	}{
+		// 50 MB in the first second
+		{50 * mb, "[##########----------]  50% 50/100 MB 50 MB/s ETA 0:01"},
+		// 10 MB/s in the next, smoothed to 0.7·50 + 0.3·10 = 38 MB/s
+		{60 * mb, "[############--------]  60% 60/100 MB 38 MB/s ETA 0:01"},
+		// No time passed: the rate stays as it was
+		{100 * mb, "[####################] 100% 100/100 MB 38 MB/s ETA 0:00"},
+	}
+	for _, tt := range tests {
+		if got := progressBar(tt.total); got != tt.want {
+			t.Errorf("progressBar(%d MB) = %q, want %q", tt.total/mb, got, tt.want)
+		}
+	}
+}
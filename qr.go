@@ -0,0 +1,100 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"strings"
+)
+
+// -qr-segments splits the encoded output into numbered segments, each
+// starting with a header line "#QR i/n", so that it can be carried by n
+// QR codes. Segments end on symbol group boundaries. Decode collects the
+// segments from all inputs, in any order, and decodes them in sequence.
+const qrHeader = "#QR "
+
+// encodeSegments encodes the whole input and writes it as n segments.
+func encodeSegments(reader *bufio.Reader, writer *bufio.Writer, encodeMap map[byte]rune, decodeMap map[rune]byte, n int, opts encodeOptions) error {
+	symbols, err := encodeWhole(reader, encodeMap, opts)
+	if err != nil {
+		return err
+	}
+	groups := len(symbols) / opts.spb
+	if groups < n {
+		return fmt.Errorf("%d bytes cannot fill %d segments", groups, n)
+	}
+	segmentOpts := opts
+	segmentOpts.finalEOL = true
+	start := 0
+	for i := 1; i <= n; i++ {
+		end := groups * i / n * opts.spb
+		if _, err := fmt.Fprintf(writer, "%s%d/%d%s", qrHeader, i, n, opts.eol); err != nil {
+			return fmt.Errorf("error writing output: %w", err)
+		}
+		segment := bufio.NewReader(strings.NewReader(string(symbols[start:end])))
+		if err := rewrap(segment, writer, decodeMap, nil, segmentOpts); err != nil {
+			return err
+		}
+		if opts.width == 0 {
+			if _, err := writer.WriteString(opts.eol); err != nil {
+				return fmt.Errorf("error writing output: %w", err)
+			}
+		}
+		start = end
+	}
+	return nil
+}
+
+// qrSegments collects the segments read by decode.
+type qrSegments struct {
+	total    int
+	segments map[int][]byte
+}
+
+func newQRSegments(total int) *qrSegments {
+	return &qrSegments{total: total, segments: make(map[int][]byte)}
+}
+
+// add splits data at its segment headers and stores the segments.
+func (q *qrSegments) add(data []byte) error {
+	current := 0
+	for _, line := range bytes.SplitAfter(data, []byte("\n")) {
+		text := strings.TrimRight(string(line), "\r\n")
+		if !strings.HasPrefix(text, qrHeader) {
+			if current == 0 && strings.TrimSpace(text) != "" {
+				return fmt.Errorf("data before the first %q header", strings.TrimSpace(qrHeader))
+			}
+			if current > 0 {
+				q.segments[current] = append(q.segments[current], line...)
+			}
+			continue
+		}
+
+		var i, n int
+		if _, err := fmt.Sscanf(text[len(qrHeader):], "%d/%d", &i, &n); err != nil {
+			return fmt.Errorf("invalid segment header %q", text)
+		}
+		if n != q.total || i < 1 || i > n {
+			return fmt.Errorf("segment header %q does not fit %d segments", text, q.total)
+		}
+		if _, dup := q.segments[i]; dup {
+			return fmt.Errorf("segment %d appears twice", i)
+		}
+		q.segments[i] = []byte{}
+		current = i
+	}
+	return nil
+}
+
+// joined returns the segments in order, once all of them were seen.
+func (q *qrSegments) joined() ([]byte, error) {
+	var all []byte
+	for i := 1; i <= q.total; i++ {
+		segment, ok := q.segments[i]
+		if !ok {
+			return nil, fmt.Errorf("segment %d of %d is missing", i, q.total)
+		}
+		all = append(all, segment...)
+	}
+	return all, nil
+}
@@ -0,0 +1,129 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"strings"
+	"testing"
+)
+
+// encodeQR encodes data as n segments and returns them separately.
+func encodeQR(t *testing.T, data []byte, n int, opts encodeOptions) []string {
+	t.Helper()
+	encodeMap, decodeMap, err := createMaps(defaultAlphabet)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var out bytes.Buffer
+	w := bufio.NewWriter(&out)
+	if err := encodeSegments(bufio.NewReader(bytes.NewReader(data)), w, encodeMap, decodeMap, n, opts); err != nil {
+		t.Fatal(err)
+	}
+	w.Flush()
+	segments := strings.SplitAfter(out.String(), qrHeader)[1:]
+	for i := range segments {
+		segments[i] = qrHeader + strings.TrimSuffix(segments[i], qrHeader)
+	}
+	if len(segments) != n {
+		t.Fatalf("%d segments, want %d", len(segments), n)
+	}
+	return segments
+}
+
+func TestQRSegments(t *testing.T) {
+	_, decodeMap, err := createMaps(defaultAlphabet)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data := []byte("segments carried by several QR codes")
+	tests := []struct {
+		name  string
+		n     int
+		opts  encodeOptions
+		order []int // of the segments as read by decode
+	}{
+		{"one segment", 1, encodeOptions{spb: 2, eol: "\n"}, []int{0}},
+		{"in order", 3, encodeOptions{spb: 2, eol: "\n"}, []int{0, 1, 2}},
+		{"shuffled", 4, encodeOptions{spb: 2, eol: "\n"}, []int{2, 0, 3, 1}},
+		{"wrapped crlf", 3, encodeOptions{spb: 2, width: 10, eol: "\r\n"}, []int{1, 2, 0}},
+		{"three symbols", 5, encodeOptions{spb: 3, width: 9, eol: "\n"}, []int{4, 3, 2, 1, 0}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			segments := encodeQR(t, data, tt.n, tt.opts)
+			q := newQRSegments(tt.n)
+			for _, i := range tt.order {
+				if err := q.add([]byte(segments[i])); err != nil {
+					t.Fatal(err)
+				}
+			}
+			joined, err := q.joined()
+			if err != nil {
+				t.Fatal(err)
+			}
+			decoded, err := decodeString(string(joined), decodeMap, decodeOptions{overflow: "error", spb: tt.opts.spb, quiet: true})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(decoded, data) {
+				t.Errorf("decoded %q, want %q", decoded, data)
+			}
+		})
+	}
+}
+
+func TestQRSegmentsErrors(t *testing.T) {
+	segments := encodeQR(t, []byte("abcdef"), 3, encodeOptions{spb: 2, eol: "\n"})
+	tests := []struct {
+		name   string
+		total  int
+		inputs []string
+	}{
+		{"missing segment", 3, []string{segments[0], segments[2]}},
+		{"segment twice", 3, []string{segments[0], segments[1], segments[1], segments[2]}},
+		{"other total", 2, []string{segments[0]}},
+		{"data before the header", 3, []string{"AB\n" + segments[0]}},
+		{"bad header", 3, []string{qrHeader + "x/3\n"}},
+	}
+	for _, tt := range tests {
+		q := newQRSegments(tt.total)
+		var err error
+		for _, input := range tt.inputs {
+			if err = q.add([]byte(input)); err != nil {
+				break
+			}
+		}
+		if err == nil {
+			_, err = q.joined()
+		}
+		if err == nil {
+			t.Errorf("%s: no error", tt.name)
+		}
+	}
+
+	encodeMap, decodeMap, err := createMaps(defaultAlphabet)
+	if err != nil {
+		t.Fatal(err)
+	}
+	w := bufio.NewWriter(&bytes.Buffer{})
+	if err := encodeSegments(bufio.NewReader(strings.NewReader("ab")), w, encodeMap, decodeMap, 3, encodeOptions{spb: 2, eol: "\n"}); err == nil {
+		t.Error("2 bytes filled 3 segments")
+	}
+}
+
+// -qr-segments holds the input and its encoding, and must stay within
+// -max-memory.
+func TestQRSegmentsMemory(t *testing.T) {
+	saved := wholeInputLimit
+	t.Cleanup(func() { wholeInputLimit = saved })
+	wholeInputLimit = 5000
+
+	encodeMap, decodeMap, err := createMaps(defaultAlphabet)
+	if err != nil {
+		t.Fatal(err)
+	}
+	w := bufio.NewWriter(&bytes.Buffer{})
+	if err := encodeSegments(bufio.NewReader(bytes.NewReader(make([]byte, 1000))), w, encodeMap, decodeMap, 2, encodeOptions{spb: 2, eol: "\n"}); err == nil {
+		t.Error("1000 bytes and their encoding fit in 5000 bytes")
+	}
+}
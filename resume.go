@@ -0,0 +1,194 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"unicode/utf8"
+)
+
+// resumePoint scans a partial encode output and returns how many of its
+// bytes form a clean prefix to keep and how many input bytes that prefix
+// represents. Wrapped output is kept up to the last complete line, so
+// that encoding can continue with an empty line buffer; unwrapped output
+// up to the last complete symbol group.
+func resumePoint(r io.Reader, decodeMap map[rune]byte, opts encodeOptions) (keep, inputBytes int64, err error) {
+	reader := bufio.NewReader(r)
+	eol := []byte(opts.eol)
+	var offset, symbols, lineSymbols int64
+
+	for {
+		if opts.width > 0 {
+			next, _ := reader.Peek(len(eol))
+			if len(next) > 0 && len(next) < len(eol) && bytes.HasPrefix(eol, next) {
+				// A line ending cut off by the interruption ends the usable output
+				return keep, inputBytes, nil
+			}
+			if bytes.Equal(next, eol) {
+				if lineSymbols%int64(opts.spb) != 0 {
+					return 0, 0, fmt.Errorf("line ending at offset %d splits a symbol group", offset)
+				}
+				reader.Discard(len(eol))
+				offset += int64(len(eol))
+				keep, inputBytes = offset, symbols/int64(opts.spb)
+				lineSymbols = 0
+				continue
+			}
+		}
+
+		// A symbol cut off by the interruption ends the usable output
+		if next, _ := reader.Peek(utf8.UTFMax); len(next) < utf8.UTFMax && !utf8.FullRune(next) {
+			return keep, inputBytes, nil
+		}
+
+		r, size, err := reader.ReadRune()
+		if err == io.EOF {
+			return keep, inputBytes, nil
+		}
+		if err != nil {
+			return 0, 0, err
+		}
+		if _, ok := decodeMap[r]; !ok {
+			return 0, 0, fmt.Errorf("invalid character at offset %d", offset)
+		}
+		offset += int64(size)
+		symbols++
+		lineSymbols++
+		if opts.width == 0 && symbols%int64(opts.spb) == 0 {
+			keep, inputBytes = offset, symbols/int64(opts.spb)
+		}
+	}
+}
+
+// skipInput positions f after the first n bytes, seeking where possible
+// and reading them otherwise.
+func skipInput(f *os.File, n int64) error {
+	if n == 0 {
+		return nil
+	}
+	if _, err := f.Seek(n, io.SeekStart); err == nil {
+		return nil
+	}
+	if _, err := io.CopyN(io.Discard, f, n); err != nil {
+		if err == io.EOF {
+			return fmt.Errorf("input is shorter than the %d bytes already encoded", n)
+		}
+		return err
+	}
+	return nil
+}
+
+// indexEntry is one "input,output" line of an encode -index, with the
+// position of the line in the index file.
+type indexEntry struct {
+	input, output int64
+	at            int64
+}
+
+// readIndex parses an encode -index written by an earlier run, which must
+// start with header. It returns the entries and where the first one
+// starts, which is the end of the header, or 0 for an empty file.
+func readIndex(r io.Reader, header string) (entries []indexEntry, start int64, err error) {
+	scanner := bufio.NewScanner(r)
+	var at int64
+	for line := 0; scanner.Scan(); line++ {
+		text := scanner.Text()
+		next := at + int64(len(text)) + 1
+		if line == 0 {
+			if text != header {
+				return nil, 0, fmt.Errorf("index does not start with %q", header)
+			}
+			start, at = next, next
+			continue
+		}
+		var entry indexEntry
+		if _, err := fmt.Sscanf(text, "%d,%d", &entry.input, &entry.output); err != nil {
+			return nil, 0, fmt.Errorf("index line %d: %q", line+1, text)
+		}
+		if n := len(entries); n > 0 && (entry.input < entries[n-1].input || entry.output < entries[n-1].output) {
+			return nil, 0, fmt.Errorf("index line %d goes backwards", line+1)
+		}
+		entry.at = at
+		entries = append(entries, entry)
+		at = next
+	}
+	return entries, start, scanner.Err()
+}
+
+// indexResumePoint is resumePoint for a run with -index: the last entry
+// whose output is complete in out marks where to continue, so the output
+// need not be parsed up to there. Entries beyond the end of out are left
+// over from the interrupted run and ignored. It also returns how many
+// symbols the kept output has on its last line, for continuing that line,
+// and where to cut the index so the resumed run rewrites the chosen entry.
+//
+// The output since the entry before the chosen one must hold exactly the
+// symbols of the input bytes in between, which catches an index that
+// belongs to another output or other settings.
+func indexResumePoint(out *os.File, entries []indexEntry, start int64, decodeMap map[rune]byte, opts encodeOptions) (keep, inputBytes int64, column int, cut int64, err error) {
+	info, err := out.Stat()
+	if err != nil {
+		return 0, 0, 0, 0, err
+	}
+	cut = start
+	var previous indexEntry
+	for i, entry := range entries {
+		if entry.output > info.Size() {
+			break
+		}
+		if i > 0 {
+			previous = entries[i-1]
+		}
+		keep, inputBytes, cut = entry.output, entry.input, entry.at
+	}
+	symbols, err := countSymbols(io.NewSectionReader(out, previous.output, keep-previous.output), decodeMap)
+	if err != nil {
+		return 0, 0, 0, 0, err
+	}
+	if want := (inputBytes - previous.input) * int64(opts.spb); symbols != want {
+		return 0, 0, 0, 0, fmt.Errorf("index entry %d,%d does not match the output: %d symbols after the entry before it, want %d", inputBytes, keep, symbols, want)
+	}
+	if opts.width == 0 || keep == 0 {
+		return keep, inputBytes, 0, cut, nil
+	}
+
+	// Count the symbols after the last line break of the kept output
+	window := int64(opts.width)*(utf8.UTFMax+int64(len(opts.groupDelimiter))) + int64(len(opts.eol))
+	from := keep - window
+	if from < 0 {
+		from = 0
+	}
+	tail := make([]byte, keep-from)
+	if _, err := out.ReadAt(tail, from); err != nil {
+		return 0, 0, 0, 0, err
+	}
+	if i := bytes.LastIndex(tail, []byte(opts.eol)); i >= 0 {
+		tail = tail[i+len(opts.eol):]
+	}
+	for _, r := range string(tail) {
+		if _, ok := decodeMap[r]; ok {
+			column++
+		}
+	}
+	return keep, inputBytes, column, cut, nil
+}
+
+// countSymbols counts the alphabet symbols in r.
+func countSymbols(r io.Reader, decodeMap map[rune]byte) (int64, error) {
+	reader := bufio.NewReader(r)
+	var n int64
+	for {
+		r, _, err := reader.ReadRune()
+		if err == io.EOF {
+			return n, nil
+		}
+		if err != nil {
+			return 0, err
+		}
+		if _, ok := decodeMap[r]; ok {
+			n++
+		}
+	}
+}
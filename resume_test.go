@@ -0,0 +1,149 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// writeResumeInput writes n bytes covering every byte value, so the
+// output has both one-byte and multi-byte symbols, and returns the path.
+func writeResumeInput(t *testing.T, dir string, n int) string {
+	t.Helper()
+	data := make([]byte, n)
+	for i := range data {
+		data[i] = byte(i*7 + i/13)
+	}
+	path := filepath.Join(dir, "input")
+	if err := os.WriteFile(path, data, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestResume(t *testing.T) {
+	dir := t.TempDir()
+	input := writeResumeInput(t, dir, 3000)
+	tests := []struct {
+		name string
+		args []string
+	}{
+		{"unwrapped", nil},
+		{"wrapped", []string{"-w", "60"}},
+		{"wrapped with final eol", []string{"-w", "60", "-final-eol"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clean := filepath.Join(dir, "clean")
+			if _, stderr, code := runMain(t, dir, "", append(tt.args, "-q", "-o", clean, input)...); code != 0 {
+				t.Fatalf("clean run: exit %d: %s", code, stderr)
+			}
+			want, err := os.ReadFile(clean)
+			if err != nil {
+				t.Fatal(err)
+			}
+			// Cuts at the start, inside a symbol, inside a line and
+			// its end of line, and after the whole output
+			for _, cut := range []int{0, 1, 2, 61, len(want) / 2, len(want)/2 + 1, len(want) - 1, len(want)} {
+				partial := filepath.Join(dir, "partial")
+				if err := os.WriteFile(partial, want[:cut], 0o644); err != nil {
+					t.Fatal(err)
+				}
+				if _, stderr, code := runMain(t, dir, "", append(tt.args, "-q", "-resume", "-o", partial, input)...); code != 0 {
+					t.Fatalf("resume after %d bytes: exit %d: %s", cut, code, stderr)
+				}
+				got, err := os.ReadFile(partial)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if string(got) != string(want) {
+					t.Errorf("resume after %d bytes: %d bytes differ from the %d of a clean run", cut, len(got), len(want))
+				}
+			}
+		})
+	}
+}
+
+func TestResumeIndex(t *testing.T) {
+	dir := t.TempDir()
+	input := writeResumeInput(t, dir, 3000)
+	args := []string{"-q", "-w", "60", "-checkpoint", "500", "-index-interval", "100"}
+	run := func(t *testing.T, out, index string, extra ...string) (string, int) {
+		t.Helper()
+		_, stderr, code := runMain(t, dir, "", append(append(args, "-o", out, "-index", index), append(extra, input)...)...)
+		return stderr, code
+	}
+	read := func(t *testing.T, path string) []byte {
+		t.Helper()
+		data, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return data
+	}
+	write := func(t *testing.T, path string, data []byte) {
+		t.Helper()
+		if err := os.WriteFile(path, data, 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	clean, cleanIndex := filepath.Join(dir, "clean"), filepath.Join(dir, "clean.idx")
+	if stderr, code := run(t, clean, cleanIndex); code != 0 {
+		t.Fatalf("clean run: exit %d: %s", code, stderr)
+	}
+	want, wantIndex := read(t, clean), read(t, cleanIndex)
+	lines := bytes.SplitAfter(wantIndex, []byte("\n"))
+
+	tests := []struct {
+		name    string
+		cut     int    // bytes of the clean output left by the interruption
+		index   []byte // the index left by it
+		wantErr bool
+	}{
+		{"nothing written", 0, lines[0], false},
+		{"index ahead of the output", len(want) / 3, wantIndex, false},
+		{"index behind the output", len(want) / 2, bytes.Join(lines[:4], nil), false},
+		{"cut inside a marker line", bytes.IndexByte(want, '~') + 1, wantIndex, false},
+		{"complete", len(want), wantIndex, false},
+		{"wrong header", len(want) / 2, append([]byte("offset,output\n"), bytes.Join(lines[1:], nil)...), true},
+		{"entries going backwards", len(want) / 2, bytes.Join([][]byte{lines[0], lines[3], lines[2]}, nil), true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			out, index := filepath.Join(dir, "partial"), filepath.Join(dir, "partial.idx")
+			write(t, out, want[:tt.cut])
+			write(t, index, tt.index)
+			stderr, code := run(t, out, index, "-resume")
+			if tt.wantErr {
+				if code == 0 {
+					t.Fatal("resumed with a broken index")
+				}
+				return
+			}
+			if code != 0 {
+				t.Fatalf("exit %d: %s", code, stderr)
+			}
+			if got := read(t, out); !bytes.Equal(got, want) {
+				t.Errorf("%d bytes of output differ from the %d of a clean run", len(got), len(want))
+			}
+			if got := read(t, index); !bytes.Equal(got, wantIndex) {
+				t.Errorf("index differs from a clean run:\n%s", got)
+			}
+		})
+	}
+
+	// An index written for unwrapped output does not fit the wrapped one
+	other, otherIndex := filepath.Join(dir, "other"), filepath.Join(dir, "other.idx")
+	if _, stderr, code := runMain(t, dir, "", "-q", "-index-interval", "100", "-o", other, "-index", otherIndex, input); code != 0 {
+		t.Fatalf("unwrapped run: exit %d: %s", code, stderr)
+	}
+	out, index := filepath.Join(dir, "partial"), filepath.Join(dir, "partial.idx")
+	write(t, out, want[:len(want)/2])
+	write(t, index, read(t, otherIndex))
+	if stderr, code := run(t, out, index, "-resume"); code == 0 || !strings.Contains(stderr, "does not match the output") {
+		t.Errorf("resumed with the index of another output: exit %d: %s", code, stderr)
+	}
+}
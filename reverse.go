@@ -0,0 +1,50 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// -reverse emits the symbol stream back to front, for consumers that read
+// it that way. The last symbol depends on the last input byte, so nothing
+// can be written before the whole input has been encoded: unlike the
+// other modes, -reverse holds the input and its encoding in memory.
+
+// encodeReversed encodes the whole input, reverses the symbols and writes
+// them wrapped as set by opts.
+func encodeReversed(reader *bufio.Reader, writer *bufio.Writer, encodeMap map[byte]rune, decodeMap map[rune]byte, opts encodeOptions) error {
+	symbols, err := encodeWhole(reader, encodeMap, opts)
+	if err != nil {
+		return err
+	}
+	reverseRunes(symbols)
+	return rewrap(bufio.NewReader(strings.NewReader(string(symbols))), writer, decodeMap, nil, opts)
+}
+
+// decodeReversed restores the symbol order of reversed input and decodes it.
+func decodeReversed(reader *bufio.Reader, writer *bufio.Writer, decodeMap map[rune]byte, opts decodeOptions) error {
+	input := &symbolReader{r: reader, sep: opts.sep, aliases: opts.aliases}
+	var symbols []rune
+	for {
+		r, _, err := input.next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return fmt.Errorf("error reading input: %w", err)
+		}
+		symbols = append(symbols, r)
+	}
+
+	reverseRunes(symbols)
+	opts.sep, opts.aliases = nil, nil
+	return decode(bufio.NewReader(strings.NewReader(string(symbols))), writer, decodeMap, opts)
+}
+
+func reverseRunes(s []rune) {
+	for i, j := 0, len(s)-1; i < j; i, j = i+1, j-1 {
+		s[i], s[j] = s[j], s[i]
+	}
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestReverse(t *testing.T) {
+	encodeMap, decodeMap, err := createMaps(defaultAlphabet)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name string
+		data string
+		opts encodeOptions
+	}{
+		{"empty", "", encodeOptions{spb: 2}},
+		{"unwrapped", "back to front", encodeOptions{spb: 2}},
+		{"wrapped", "back to front", encodeOptions{spb: 2, width: 6, eol: "\n", finalEOL: true}},
+		{"three symbols", "back to front", encodeOptions{spb: 3, width: 7, eol: "\r\n"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var out bytes.Buffer
+			w := bufio.NewWriter(&out)
+			if err := encodeReversed(bufio.NewReader(strings.NewReader(tt.data)), w, encodeMap, decodeMap, tt.opts); err != nil {
+				t.Fatal(err)
+			}
+			w.Flush()
+
+			forward := []rune(encodeString(t, []byte(tt.data), encodeMap, encodeOptions{spb: tt.opts.spb}))
+			reverseRunes(forward)
+			if got := strings.NewReplacer("\r", "", "\n", "").Replace(out.String()); got != string(forward) {
+				t.Errorf("encoded %q, want %q", got, string(forward))
+			}
+
+			var decoded bytes.Buffer
+			w = bufio.NewWriter(&decoded)
+			if err := decodeReversed(bufio.NewReader(&out), w, decodeMap, decodeOptions{overflow: "error", spb: tt.opts.spb, quiet: true}); err != nil {
+				t.Fatal(err)
+			}
+			w.Flush()
+			if decoded.String() != tt.data {
+				t.Errorf("decoded %q, want %q", decoded.String(), tt.data)
+			}
+		})
+	}
+}
+
+// The reversed encoding is held in memory and counts against -max-memory.
+func TestReverseMemory(t *testing.T) {
+	saved := wholeInputLimit
+	t.Cleanup(func() { wholeInputLimit = saved })
+	wholeInputLimit = 5000
+
+	encodeMap, decodeMap, err := createMaps(defaultAlphabet)
+	if err != nil {
+		t.Fatal(err)
+	}
+	w := bufio.NewWriter(&bytes.Buffer{})
+	if err := encodeReversed(bufio.NewReader(bytes.NewReader(make([]byte, 1000))), w, encodeMap, decodeMap, encodeOptions{spb: 2}); err == nil {
+		t.Error("1000 bytes and their encoding fit in 5000 bytes")
+	}
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+)
+
+// rewrap copies the symbols of encoded input to writer, wrapped at
+// opts.width as encode would have done, without decoding them. Line
+// breaks, sep, markers, padding and comment lines are dropped, except that
+// a -digest-line is written again at the end; any other rune must be an
+// alphabet symbol.
+func rewrap(reader *bufio.Reader, writer *bufio.Writer, decodeMap map[rune]byte, sep []byte, opts encodeOptions) error {
+	symbols := newSymbolReader(reader, decodeMap, decodeOptions{sep: sep})
+	lineBuffer := make([]rune, 0, opts.width)
+	count := 0
+
+	for {
+		r, offset, err := symbols.next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return fmt.Errorf("error reading input: %w", err)
+		}
+		if _, ok := decodeMap[r]; !ok {
+			return fmt.Errorf("invalid character in input at offset %d", offset)
+		}
+		lineBuffer = append(lineBuffer, r)
+		count++
+
+		// Break lines only after complete symbol groups, like encode
+		if count%opts.spb != 0 {
+			continue
+		}
+		if opts.width > 0 && len(lineBuffer) >= opts.width {
+			if _, err := writer.WriteString(string(lineBuffer) + opts.eol); err != nil {
+				return fmt.Errorf("error writing output: %w", err)
+			}
+			lineBuffer = lineBuffer[:0]
+		}
+		if opts.width == 0 && len(lineBuffer) >= unwrappedChunk {
+			if _, err := writer.WriteString(string(lineBuffer)); err != nil {
+				return fmt.Errorf("error writing output: %w", err)
+			}
+			lineBuffer = lineBuffer[:0]
+		}
+	}
+
+	if count%opts.spb != 0 {
+		return fmt.Errorf("unexpected EOF: input length is not a multiple of %d", opts.spb)
+	}
+	if len(lineBuffer) > 0 {
+		tail := string(lineBuffer)
+		if opts.width > 0 && opts.finalEOL {
+			tail += opts.eol
+		}
+		if _, err := writer.WriteString(tail); err != nil {
+			return fmt.Errorf("error writing final output: %w", err)
+		}
+	}
+	if symbols.digest != "" {
+		line := digestMarker + symbols.digest + opts.eol
+		if len(lineBuffer) > 0 && (opts.width == 0 || !opts.finalEOL) {
+			line = opts.eol + line
+		}
+		if _, err := writer.WriteString(line); err != nil {
+			return fmt.Errorf("error writing final output: %w", err)
+		}
+	}
+	return nil
+}
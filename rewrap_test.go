@@ -0,0 +1,83 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestRewrap(t *testing.T) {
+	encodeMap, decodeMap, err := createMaps(defaultAlphabet)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data := make([]byte, 200)
+	for i := range data {
+		data[i] = byte(i * 13)
+	}
+	sources := map[string]encodeOptions{
+		"unwrapped":     {spb: 2},
+		"crlf at 60":    {spb: 2, width: 60, eol: "\r\n"},
+		"lf at 7":       {spb: 2, width: 7, eol: "\n", finalEOL: true},
+		"checkpoints":   {spb: 2, width: 20, eol: "\n", checkpoint: 9, flush: func() error { return nil }},
+		"padded to 50":  {spb: 2, width: 50, eol: "\n", padFinal: true},
+		"separator eol": {spb: 2, width: 30, eol: "\x1e"},
+	}
+	targets := []encodeOptions{
+		{spb: 2},
+		{spb: 2, width: 76, eol: "\r\n"},
+		{spb: 2, width: 9, eol: "\n", finalEOL: true},
+	}
+	for name, source := range sources {
+		text := encodeString(t, data, encodeMap, source)
+		for _, target := range targets {
+			var out bytes.Buffer
+			w := bufio.NewWriter(&out)
+			if err := rewrap(bufio.NewReader(strings.NewReader(text)), w, decodeMap, []byte(source.eol), target); err != nil {
+				t.Fatalf("%s to width %d: %v", name, target.width, err)
+			}
+			w.Flush()
+			if want := encodeString(t, data, encodeMap, target); out.String() != want {
+				t.Errorf("%s to width %d gave\n%q\nwant\n%q", name, target.width, out.String(), want)
+			}
+		}
+	}
+}
+
+func TestRewrapErrors(t *testing.T) {
+	_, decodeMap, err := createMaps(defaultAlphabet)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for name, text := range map[string]string{
+		"lowercase symbol": "ABcD",
+		"digit":            "AB\n12",
+		"half a group":     "ABC",
+	} {
+		var out bytes.Buffer
+		if err := rewrap(bufio.NewReader(strings.NewReader(text)), bufio.NewWriter(&out), decodeMap, nil, encodeOptions{spb: 2, width: 4, eol: "\n"}); err == nil {
+			t.Errorf("%s: rewrapped %q", name, text)
+		}
+	}
+}
+
+// A -digest-line survives rewrapping and still checks the decoded data.
+func TestRewrapDigest(t *testing.T) {
+	input := "rewrapped with a digest"
+	encoded, stderr, code := runMain(t, "", input, "-q", "-w", "8", "-digest-line", "sha256")
+	if code != 0 {
+		t.Fatalf("encode: exit %d: %s", code, stderr)
+	}
+	rewrapped, stderr, code := runMain(t, "", encoded, "-q", "-rewrap", "-w", "12")
+	if code != 0 {
+		t.Fatalf("rewrap: exit %d: %s", code, stderr)
+	}
+	if !strings.Contains(rewrapped, digestMarker) {
+		t.Fatalf("digest line dropped:\n%s", rewrapped)
+	}
+	decoded, stderr, code := runMain(t, "", rewrapped, "-q", "-d", "-digest-line", "sha256")
+	if code != 0 || decoded != input {
+		t.Errorf("decoded %q, exit %d: %s", decoded, code, stderr)
+	}
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"sync/atomic"
+	"time"
+)
+
+// rotateTemplate names the -rotate output files; it is a time.Format
+// layout applied to the start of each interval. rotateSuffix is appended
+// afterwards, as its digits would be taken for a layout element.
+const (
+	rotateTemplate = "capture-20060102-15"
+	rotateSuffix   = ".c30"
+)
+
+// rotatingFile is an output that moves on to a new file every interval.
+// The switch itself is left to the encoder, which calls rotate at a
+// symbol group boundary once due is set.
+type rotatingFile struct {
+	template string
+	interval time.Duration
+	f        *os.File
+	names    []string // every file written, in order
+	due      atomic.Bool
+}
+
+func newRotatingFile(template string, interval time.Duration) (*rotatingFile, error) {
+	r := &rotatingFile{template: template, interval: interval}
+	if err := r.open(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// open opens the file for the current interval and arms the timer for the
+// next one. Files are appended to, so a restarted capture continues the
+// file of its interval.
+func (r *rotatingFile) open() error {
+	start := now().Truncate(r.interval)
+	name := start.Format(r.template) + rotateSuffix
+	if r.f == nil || name != r.names[len(r.names)-1] {
+		f, err := os.OpenFile(name, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0o644)
+		if err != nil {
+			return err
+		}
+		if r.f != nil {
+			if err := r.f.Close(); err != nil {
+				f.Close()
+				return err
+			}
+		}
+		r.f = f
+		r.names = append(r.names, name)
+	}
+	time.AfterFunc(start.Add(r.interval).Sub(now()), func() { r.due.Store(true) })
+	return nil
+}
+
+// rotate switches to the file of the current interval.
+func (r *rotatingFile) rotate() error {
+	r.due.Store(false)
+	return r.open()
+}
+
+func (r *rotatingFile) Write(p []byte) (int, error) {
+	return r.f.Write(p)
+}
+
+func (r *rotatingFile) Close() error {
+	return r.f.Close()
+}
+
+// parseRotate checks a -rotate interval.
+func parseRotate(s string) (time.Duration, error) {
+	d, err := time.ParseDuration(s)
+	if err != nil {
+		return 0, err
+	}
+	if d < time.Second {
+		return 0, fmt.Errorf("interval %v is shorter than a second", d)
+	}
+	return d, nil
+}
@@ -0,0 +1,26 @@
+package main
+
+import "strings"
+
+// rulerRunes are the characters of a -ruler line. None may be an alphabet
+// symbol, so that the line stays recognizable as a comment.
+const rulerRunes = "#.+0123456789"
+
+// ruler returns a comment line as wide as width symbols that numbers every
+// tenth column and marks every fifth, like "#...+....1....+....2". The
+// comment marker takes the place of the first column.
+func ruler(width int) string {
+	var b strings.Builder
+	b.WriteRune(commentMarker)
+	for column := 2; column <= width; column++ {
+		switch {
+		case column%10 == 0:
+			b.WriteByte(byte('0' + column/10%10))
+		case column%5 == 0:
+			b.WriteByte('+')
+		default:
+			b.WriteByte('.')
+		}
+	}
+	return b.String()
+}
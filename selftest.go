@@ -0,0 +1,92 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+)
+
+// selfTestVectors are known answers as digit values, least significant
+// first, for the default two symbols per byte. Longer groups pad with 0.
+var selfTestVectors = []struct {
+	input  byte
+	digits []byte
+}{
+	{0, []byte{0, 0}},
+	{29, []byte{29, 0}},
+	{255, []byte{15, 8}},
+}
+
+// selfTest runs the known-answer vectors through encode and decode with
+// the active alphabet and symbols per byte.
+func selfTest(encodeMap map[byte]rune, decodeMap map[rune]byte, spb int) error {
+	for _, v := range selfTestVectors {
+		var want []rune
+		for i := 0; i < spb; i++ {
+			digit := byte(0)
+			if i < len(v.digits) {
+				digit = v.digits[i]
+			}
+			want = append(want, encodeMap[digit])
+		}
+
+		var encoded bytes.Buffer
+		w := bufio.NewWriter(&encoded)
+		if err := encode(bufio.NewReader(bytes.NewReader([]byte{v.input})), w, encodeMap, encodeOptions{spb: spb}); err != nil {
+			return err
+		}
+		w.Flush()
+		if encoded.String() != string(want) {
+			return fmt.Errorf("byte %d encoded as %q, want %q", v.input, encoded.String(), string(want))
+		}
+
+		var decoded bytes.Buffer
+		w = bufio.NewWriter(&decoded)
+		if err := decode(bufio.NewReader(&encoded), w, decodeMap, decodeOptions{overflow: "error", spb: spb, quiet: true}); err != nil {
+			return err
+		}
+		w.Flush()
+		if !bytes.Equal(decoded.Bytes(), []byte{v.input}) {
+			return fmt.Errorf("%q decoded as %v, want [%d]", string(want), decoded.Bytes(), v.input)
+		}
+	}
+	return nil
+}
+
+// checkRoundTrip encodes all 256 byte values with the active alphabets and
+// symbols per byte and makes sure they decode again, folding variants
+// when decode would. It catches alphabets that cannot work before any
+// real data is touched.
+func checkRoundTrip(encodeMap map[byte]rune, decodeMap map[rune]byte, enc encodeOptions, dec decodeOptions) error {
+	all := make([]byte, 256)
+	for i := range all {
+		all[i] = byte(i)
+	}
+	if enc.oddMap != nil {
+		// Every value once at an even and once at an odd index
+		all = append(append(all, 0), all[:256]...)
+	}
+
+	var encoded bytes.Buffer
+	w := bufio.NewWriter(&encoded)
+	if err := encode(bufio.NewReader(bytes.NewReader(all)), w, encodeMap, encodeOptions{spb: enc.spb, oddMap: enc.oddMap}); err != nil {
+		return err
+	}
+	w.Flush()
+
+	var decoded bytes.Buffer
+	w = bufio.NewWriter(&decoded)
+	if err := decode(bufio.NewReader(&encoded), w, decodeMap, decodeOptions{overflow: "error", spb: dec.spb, fold: dec.fold, oddMap: dec.oddMap, quiet: true}); err != nil {
+		return err
+	}
+	w.Flush()
+	for i, b := range decoded.Bytes() {
+		if b != all[i] {
+			return fmt.Errorf("byte %d decodes as %d", all[i], b)
+		}
+	}
+	if decoded.Len() != len(all) {
+		return fmt.Errorf("%d byte values decode to %d bytes", len(all), decoded.Len())
+	}
+	return nil
+}
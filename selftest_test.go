@@ -0,0 +1,74 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestCheckRoundTrip(t *testing.T) {
+	maps := func(alphabet string) (map[byte]rune, map[rune]byte) {
+		encodeMap, decodeMap, err := createMaps(alphabet)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return encodeMap, decodeMap
+	}
+	encodeMap, decodeMap := maps(defaultAlphabet)
+	_, lowerDecode := maps(strings.ToLower(defaultAlphabet))
+	_, altEncode, altDecode, oddEncode, oddDecode, err := altMaps(defaultAlphabet + ";" + strings.ToLower(defaultAlphabet))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Two symbols that decode as each other's value
+	swapped := make(map[rune]byte)
+	for r, v := range decodeMap {
+		swapped[r] = v
+	}
+	swapped['A'], swapped['B'] = decodeMap['B'], decodeMap['A']
+
+	tests := []struct {
+		name      string
+		encodeMap map[byte]rune
+		decodeMap map[rune]byte
+		enc       encodeOptions
+		dec       decodeOptions
+		wantErr   bool
+	}{
+		{"default", encodeMap, decodeMap, encodeOptions{spb: 2}, decodeOptions{spb: 2}, false},
+		{"eight symbols", encodeMap, decodeMap, encodeOptions{spb: 8}, decodeOptions{spb: 8}, false},
+		{"folded", encodeMap, decodeMap, encodeOptions{spb: 2}, decodeOptions{spb: 2, fold: true}, false},
+		{"alternating alphabets", altEncode, altDecode, encodeOptions{spb: 2, oddMap: oddEncode}, decodeOptions{spb: 2, oddMap: oddDecode}, false},
+		{"swapped symbols", encodeMap, swapped, encodeOptions{spb: 2}, decodeOptions{spb: 2}, true},
+		{"other alphabet", encodeMap, lowerDecode, encodeOptions{spb: 2}, decodeOptions{spb: 2}, true},
+		{"symbols per byte differ", encodeMap, decodeMap, encodeOptions{spb: 2}, decodeOptions{spb: 3}, true},
+		{"odd alphabet only when decoding", altEncode, altDecode, encodeOptions{spb: 2}, decodeOptions{spb: 2, oddMap: oddDecode}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := checkRoundTrip(tt.encodeMap, tt.decodeMap, tt.enc, tt.dec)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("error %v, want error %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestCheckConfigFlag(t *testing.T) {
+	tests := []struct {
+		args     []string
+		wantCode int
+		want     string
+	}{
+		{[]string{"-check-config"}, 0, "Configuration OK"},
+		{[]string{"-check-config", "-a", "0123456789abcdefghijklmnopqrst", "-spb", "3"}, 0, "Configuration OK"},
+		{[]string{"-check-config", "-d", "-fold", "-a", "ABCDEFGHIJKLMNOPQRSTUVWXYZabcd"}, 1, "merge"},
+	}
+	for _, tt := range tests {
+		// The check exits before reading any input
+		_, stderr, code := runMain(t, "", "", tt.args...)
+		if code != tt.wantCode || !strings.Contains(stderr, tt.want) {
+			t.Errorf("%v: exit %d: %s", tt.args, code, stderr)
+		}
+	}
+}
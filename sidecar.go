@@ -0,0 +1,54 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// sidecarSuffix is appended to an input file name to find its metadata.
+const sidecarSuffix = ".meta"
+
+// readSidecar reads the key=value lines of the metadata file next to the
+// named input. Blank lines and lines starting with '#' are ignored.
+func readSidecar(name string) (map[string]string, error) {
+	f, err := os.Open(name + sidecarSuffix)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	meta := make(map[string]string)
+	scanner := bufio.NewScanner(f)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+		key, value, ok := strings.Cut(text, "=")
+		if !ok {
+			return nil, fmt.Errorf("%s%s:%d: expected key=value", name, sidecarSuffix, line)
+		}
+		meta[strings.TrimSpace(key)] = strings.TrimSpace(value)
+	}
+	return meta, scanner.Err()
+}
+
+// sidecarAlphabet returns the decode map for the alphabet recorded in the
+// sidecar of the named input, together with the alphabet itself.
+func sidecarAlphabet(name string) (map[rune]byte, string, error) {
+	meta, err := readSidecar(name)
+	if err != nil {
+		return nil, "", err
+	}
+	alphabet, ok := meta["alphabet"]
+	if !ok {
+		return nil, "", fmt.Errorf("%s%s has no alphabet entry", name, sidecarSuffix)
+	}
+	_, decodeMap, err := createMaps(alphabet)
+	if err != nil {
+		return nil, "", fmt.Errorf("invalid alphabet in %s%s: %w", name, sidecarSuffix, err)
+	}
+	return decodeMap, alphabet, nil
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"encoding/json"
+	"math"
+	"os"
+)
+
+// entropy returns the Shannon entropy of a byte histogram in bits per
+// byte: 8 for uniformly random data, 0 for a single repeated value.
+func entropy(histogram *[256]int64) float64 {
+	var total int64
+	for _, n := range histogram {
+		total += n
+	}
+	if total == 0 {
+		return 0
+	}
+
+	bits := 0.0
+	for _, n := range histogram {
+		if n == 0 {
+			continue
+		}
+		p := float64(n) / float64(total)
+		bits -= p * math.Log2(p)
+	}
+	return bits
+}
+
+// byteHistogram counts the byte values written to it, for -entropy.
+type byteHistogram [256]int64
+
+func (h *byteHistogram) Write(p []byte) (int, error) {
+	for _, b := range p {
+		h[b]++
+	}
+	return len(p), nil
+}
+
+// symbolCounts derives how often each alphabet symbol was emitted for the
+// data bytes counted in histogram, keyed by the symbol as a string. With
+// -alt-alphabets, odd holds the bytes at odd indexes, which histogram also
+// counts, and oddMap the alphabet they were written with.
+func symbolCounts(histogram, odd *[256]int64, encodeMap, oddMap map[byte]rune, spb int) map[string]int64 {
+	counts := make(map[string]int64, len(encodeMap)+len(oddMap))
+	for _, digits := range []map[byte]rune{encodeMap, oddMap} {
+		for _, symbol := range digits {
+			counts[string(symbol)] = 0
+		}
+	}
+	add := func(b byte, n int64, digits map[byte]rune) {
+		for i := 0; i < spb; i++ {
+			counts[string(digits[b%30])] += n
+			b /= 30
+		}
+	}
+	for value, n := range histogram {
+		if odd != nil {
+			add(byte(value), odd[value], oddMap)
+			n -= odd[value]
+		}
+		if n > 0 {
+			add(byte(value), n, encodeMap)
+		}
+	}
+	return counts
+}
+
+// writeSymbolStats writes the symbol counts to path as a JSON object.
+func writeSymbolStats(path string, counts map[string]int64) error {
+	data, err := json.Marshal(counts)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, append(data, '\n'), 0o644)
+}
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"strings"
+	"testing"
+)
+
+func TestEntropy(t *testing.T) {
+	all := make([]byte, 256)
+	for i := range all {
+		all[i] = byte(i)
+	}
+	tests := []struct {
+		data string
+		want float64
+	}{
+		{"", 0},
+		{"aaaa", 0},
+		{"abab", 1},
+		{"abcd", 2},
+		{string(all), 8},
+	}
+	for _, tt := range tests {
+		var h byteHistogram
+		fmt.Fprint(&h, tt.data)
+		if got := entropy((*[256]int64)(&h)); math.Abs(got-tt.want) > 1e-9 {
+			t.Errorf("entropy of %q = %v, want %v", tt.data, got, tt.want)
+		}
+	}
+}
+
+// -entropy describes the input, not the bytes the transforms before the
+// codec turn it into.
+func TestEntropyOfInput(t *testing.T) {
+	for _, args := range [][]string{
+		nil,
+		{"-length-prefix"},
+		{"-ecc"},
+		{"-pipeline", "gzip,xor:k,encode"},
+		{"-dedup-block", "4"},
+	} {
+		_, stderr, code := runMain(t, "", strings.Repeat("x", 100), append(args, "-entropy")...)
+		if code != 0 {
+			t.Fatalf("%v: exit %d: %s", args, code, stderr)
+		}
+		if !strings.Contains(stderr, "Entropy: 0.000 bits/byte") {
+			t.Errorf("%v: %q, want the entropy of the repeated byte", args, stderr)
+		}
+	}
+}
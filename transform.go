@@ -0,0 +1,453 @@
+package main
+
+import (
+	"bufio"
+	"compress/gzip"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"os"
+	"unicode/utf8"
+)
+
+// outputChain stacks output transforms in front of a final buffered
+// writer. The codec writes to top; every layer has its own buffer.
+type outputChain struct {
+	top    *bufio.Writer
+	layers []*bufio.Writer
+	closes []func() error // per layer, nil if there is nothing to close
+}
+
+func newOutputChain(w io.Writer) *outputChain {
+	c := &outputChain{}
+	c.push(w, nil)
+	return c
+}
+
+// push puts stage in front of the chain. Stages usually write to the
+// previous top; closeStage, if set, finishes the stage after its buffer
+// was flushed.
+func (c *outputChain) push(stage io.Writer, closeStage func() error) {
+	c.top = bufio.NewWriterSize(stage, bufferSize)
+	c.layers = append(c.layers, c.top)
+	c.closes = append(c.closes, closeStage)
+}
+
+// attach sets the writer at the bottom of the chain, for a chain that
+// was created before its output was opened.
+func (c *outputChain) attach(w io.Writer) {
+	c.layers[0].Reset(w)
+}
+
+// flush pushes buffered data through every layer without closing stages.
+func (c *outputChain) flush() error {
+	for i := len(c.layers) - 1; i >= 0; i-- {
+		if err := c.layers[i].Flush(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// close flushes every layer and finishes every stage, outermost first.
+func (c *outputChain) close() error {
+	for i := len(c.layers) - 1; i >= 0; i-- {
+		if err := c.layers[i].Flush(); err != nil {
+			return err
+		}
+		if c.closes[i] != nil {
+			if err := c.closes[i](); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// spaceSkipper drops ASCII whitespace from the underlying reader, so that
+// textual input such as wrapped hex dumps can be fed to a strict decoder.
+type spaceSkipper struct {
+	r io.Reader
+}
+
+func (s spaceSkipper) Read(p []byte) (int, error) {
+	for {
+		n, err := s.r.Read(p)
+		kept := 0
+		for _, b := range p[:n] {
+			switch b {
+			case ' ', '\t', '\r', '\n', '\v', '\f':
+				continue
+			}
+			p[kept] = b
+			kept++
+		}
+		if kept > 0 || err != nil {
+			return kept, err
+		}
+	}
+}
+
+// previewSymbols is the number of encoded symbols shown by -preview.
+const previewSymbols = 100
+
+// previewWriter collects the first limit symbols written to it, ignoring
+// line breaks, and prints them to stderr once enough have arrived. It
+// never fails, so it can sit next to the real output in an io.MultiWriter.
+type previewWriter struct {
+	limit int
+	buf   []rune
+	shown bool
+}
+
+func (p *previewWriter) Write(b []byte) (int, error) {
+	if p.shown {
+		return len(b), nil
+	}
+	for _, r := range string(b) {
+		if r == '\r' || r == '\n' {
+			continue
+		}
+		p.buf = append(p.buf, r)
+		if len(p.buf) == p.limit {
+			p.show()
+			break
+		}
+	}
+	return len(b), nil
+}
+
+// show prints whatever has been collected, unless it was shown already.
+func (p *previewWriter) show() {
+	if p.shown {
+		return
+	}
+	p.shown = true
+	fmt.Fprintf(os.Stderr, "Preview: %s\n", string(p.buf))
+}
+
+// jsonEnvelope wraps everything written to it as {"bytes":"...","len":N},
+// with the bytes in standard base64, which needs no JSON escaping. The
+// length comes last so the envelope can be streamed.
+type jsonEnvelope struct {
+	w   io.Writer
+	b64 io.WriteCloser
+	n   int64
+}
+
+func (j *jsonEnvelope) start() error {
+	if j.b64 != nil {
+		return nil
+	}
+	if _, err := io.WriteString(j.w, `{"bytes":"`); err != nil {
+		return err
+	}
+	j.b64 = base64.NewEncoder(base64.StdEncoding, j.w)
+	return nil
+}
+
+func (j *jsonEnvelope) Write(p []byte) (int, error) {
+	if err := j.start(); err != nil {
+		return 0, err
+	}
+	n, err := j.b64.Write(p)
+	j.n += int64(n)
+	return n, err
+}
+
+// Close completes the envelope; it does not close the underlying writer.
+func (j *jsonEnvelope) Close() error {
+	if err := j.start(); err != nil {
+		return err
+	}
+	if err := j.b64.Close(); err != nil {
+		return err
+	}
+	_, err := fmt.Fprintf(j.w, "\",\"len\":%d}\n", j.n)
+	return err
+}
+
+// valuesPerLine is the number of byte values on each line written by a
+// byteValueWriter.
+const valuesPerLine = 16
+
+// byteValueWriter writes each byte as a number in base 10 or 16, separated
+// by spaces and with a line break after every valuesPerLine values.
+type byteValueWriter struct {
+	w      io.Writer
+	format string // "%d" or "%02x"
+	n      int64
+	buf    []byte
+}
+
+func (b *byteValueWriter) Write(p []byte) (int, error) {
+	b.buf = b.buf[:0]
+	for _, v := range p {
+		if b.n > 0 {
+			sep := byte(' ')
+			if b.n%valuesPerLine == 0 {
+				sep = '\n'
+			}
+			b.buf = append(b.buf, sep)
+		}
+		b.buf = fmt.Appendf(b.buf, b.format, v)
+		b.n++
+	}
+	if _, err := b.w.Write(b.buf); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// Close ends the last line; it does not close the underlying writer.
+func (b *byteValueWriter) Close() error {
+	if b.n == 0 {
+		return nil
+	}
+	_, err := io.WriteString(b.w, "\n")
+	return err
+}
+
+// isGzip reports whether b starts with the gzip magic number.
+func isGzip(b []byte) bool {
+	return len(b) >= 2 && b[0] == 0x1f && b[1] == 0x8b
+}
+
+// gunzipWriter decompresses the gzip stream written to it into w.
+type gunzipWriter struct {
+	pipe *io.PipeWriter
+	done chan error
+}
+
+func newGunzipWriter(w io.Writer) *gunzipWriter {
+	pr, pw := io.Pipe()
+	g := &gunzipWriter{pipe: pw, done: make(chan error, 1)}
+	go func() {
+		zr, err := gzip.NewReader(pr)
+		if err == nil {
+			_, err = io.Copy(w, zr)
+		}
+		pr.CloseWithError(err)
+		g.done <- err
+	}()
+	return g
+}
+
+func (g *gunzipWriter) Write(p []byte) (int, error) {
+	return g.pipe.Write(p)
+}
+
+// Close waits for decompression to finish; it does not close w.
+func (g *gunzipWriter) Close() error {
+	g.pipe.Close()
+	if err := <-g.done; err != nil {
+		return fmt.Errorf("gunzip: %w", err)
+	}
+	return nil
+}
+
+// gzipSniffer looks at the first two bytes written to it. Gzip data is
+// decompressed on its way to w; anything else passes through unchanged.
+type gzipSniffer struct {
+	w      io.Writer
+	head   []byte
+	gunzip *gunzipWriter // set once gzip data was detected
+	passed bool          // set once plain data was detected
+}
+
+func (g *gzipSniffer) Write(p []byte) (int, error) {
+	switch {
+	case g.gunzip != nil:
+		return g.gunzip.Write(p)
+	case g.passed:
+		return g.w.Write(p)
+	}
+
+	g.head = append(g.head, p...)
+	if len(g.head) < 2 {
+		return len(p), nil
+	}
+	if err := g.start(); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// start decides on the collected head and sends it on.
+func (g *gzipSniffer) start() error {
+	if !isGzip(g.head) {
+		g.passed = true
+		_, err := g.w.Write(g.head)
+		return err
+	}
+	g.gunzip = newGunzipWriter(g.w)
+	_, err := g.gunzip.Write(g.head)
+	return err
+}
+
+// Close finishes decompression, if any; it does not close w.
+func (g *gzipSniffer) Close() error {
+	if g.gunzip == nil && !g.passed {
+		if len(g.head) == 0 {
+			return nil
+		}
+		if err := g.start(); err != nil {
+			return err
+		}
+	}
+	if g.gunzip == nil {
+		return nil
+	}
+	return g.gunzip.Close()
+}
+
+// utf8Checker passes data through while making sure it is valid UTF-8.
+// A rune split between two writes is held back until it is complete.
+type utf8Checker struct {
+	w      io.Writer
+	carry  []byte
+	offset int64 // bytes checked so far
+}
+
+func (u *utf8Checker) Write(p []byte) (int, error) {
+	buf := append(u.carry, p...)
+
+	// Hold back an incomplete rune at the end
+	end := len(buf)
+	for i := len(buf) - 1; i >= 0 && i >= len(buf)-utf8.UTFMax+1; i-- {
+		if utf8.RuneStart(buf[i]) {
+			if !utf8.FullRune(buf[i:]) {
+				end = i
+			}
+			break
+		}
+	}
+
+	for i := 0; i < end; {
+		r, size := utf8.DecodeRune(buf[i:end])
+		if r == utf8.RuneError && size <= 1 {
+			return 0, fmt.Errorf("decoded output is not valid UTF-8 at byte %d", u.offset+int64(i))
+		}
+		i += size
+	}
+	u.offset += int64(end)
+	u.carry = append(u.carry[:0], buf[end:]...)
+	return u.w.Write(p)
+}
+
+// Close reports a rune left incomplete at the end of the output.
+func (u *utf8Checker) Close() error {
+	if len(u.carry) > 0 {
+		return fmt.Errorf("decoded output is not valid UTF-8 at byte %d", u.offset)
+	}
+	return nil
+}
+
+// zeroTrimmer drops the zero bytes at the end of each record. Zeros are
+// held back until a non-zero byte shows they were not trailing; drop
+// discards them at the end of a record. This is lossy for records that
+// legitimately end in zeros.
+type zeroTrimmer struct {
+	w     io.Writer
+	zeros int
+}
+
+// zeroRun is written in place of held-back zeros that turned out not to
+// be trailing.
+var zeroRun [4096]byte
+
+func (z *zeroTrimmer) Write(p []byte) (int, error) {
+	end := len(p)
+	for end > 0 && p[end-1] == 0 {
+		end--
+	}
+	if end == 0 {
+		z.zeros += len(p)
+		return len(p), nil
+	}
+
+	// A non-zero byte follows the held zeros, so they were data
+	for z.zeros > 0 {
+		n := z.zeros
+		if n > len(zeroRun) {
+			n = len(zeroRun)
+		}
+		if _, err := z.w.Write(zeroRun[:n]); err != nil {
+			return 0, err
+		}
+		z.zeros -= n
+	}
+	if _, err := z.w.Write(p[:end]); err != nil {
+		return 0, err
+	}
+	z.zeros = len(p) - end
+	return len(p), nil
+}
+
+// drop discards the zeros held back at the end of a record.
+func (z *zeroTrimmer) drop() {
+	z.zeros = 0
+}
+
+// windowWriter passes on only a window of the bytes written to it: it
+// drops the first skip bytes and then keeps at most remain bytes, or all
+// of them when remain is negative.
+type windowWriter struct {
+	w      io.Writer
+	skip   int64
+	remain int64
+}
+
+func (v *windowWriter) Write(p []byte) (int, error) {
+	n := len(p)
+	if v.skip >= int64(len(p)) {
+		v.skip -= int64(len(p))
+		return n, nil
+	}
+	p = p[v.skip:]
+	v.skip = 0
+	if v.remain >= 0 {
+		if v.remain < int64(len(p)) {
+			p = p[:v.remain]
+		}
+		v.remain -= int64(len(p))
+	}
+	if _, err := v.w.Write(p); err != nil {
+		return 0, err
+	}
+	return n, nil
+}
+
+// countingReader counts the bytes read through it into n, which the
+// readers of several inputs may share.
+type countingReader struct {
+	r io.Reader
+	n *int64
+}
+
+func (c countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	*c.n += int64(n)
+	return n, err
+}
+
+// countingWriter counts the bytes written through it.
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.n += int64(n)
+	return n, err
+}
+
+// checkRecordLength enforces -strict-length: n bytes must make up whole
+// records of size bytes.
+func checkRecordLength(what string, n, size int64) error {
+	if n%size != 0 {
+		return fmt.Errorf("%s %d bytes, not a multiple of %d (%d bytes short of the next record)", what, n, size, size-n%size)
+	}
+	return nil
+}
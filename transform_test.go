@@ -0,0 +1,42 @@
+package main
+
+import (
+	"bytes"
+	"encoding/hex"
+	"io"
+	"strings"
+	"testing"
+)
+
+// -from-hex reads hex dumps through spaceSkipper, and -to-hex writes them.
+func TestHexRoundTrip(t *testing.T) {
+	data := []byte("\x00\x01 hex \xfe\xff")
+	tests := []struct {
+		name string
+		dump string
+	}{
+		{"plain", hex.EncodeToString(data)},
+		{"upper case", strings.ToUpper(hex.EncodeToString(data))},
+		{"wrapped", "0001206865\r\n78\t20feff\n"},
+		{"spaced", hex.Dump(data)[10:57]},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := io.ReadAll(hex.NewDecoder(spaceSkipper{strings.NewReader(tt.dump)}))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(got, data) {
+				t.Errorf("%q read as %q, want %q", tt.dump, got, data)
+			}
+
+			var dump bytes.Buffer
+			if _, err := hex.NewEncoder(&dump).Write(got); err != nil {
+				t.Fatal(err)
+			}
+			if dump.String() != hex.EncodeToString(data) {
+				t.Errorf("written as %q", dump.String())
+			}
+		})
+	}
+}
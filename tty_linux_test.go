@@ -0,0 +1,115 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"syscall"
+	"testing"
+	"unsafe"
+)
+
+// openPTY opens a pseudo terminal and returns its terminal side, which
+// stays usable until the test ends. It skips the test where there is none.
+func openPTY(t *testing.T) *os.File {
+	t.Helper()
+	ptmx, err := os.OpenFile("/dev/ptmx", os.O_RDWR, 0)
+	if err != nil {
+		t.Skipf("no pseudo terminal: %v", err)
+	}
+	t.Cleanup(func() { ptmx.Close() })
+	var n, unlock uint32
+	if _, _, errno := syscall.Syscall(syscall.SYS_IOCTL, ptmx.Fd(), syscall.TIOCSPTLCK, uintptr(unsafe.Pointer(&unlock))); errno != 0 {
+		t.Skipf("cannot unlock the pseudo terminal: %v", errno)
+	}
+	if _, _, errno := syscall.Syscall(syscall.SYS_IOCTL, ptmx.Fd(), syscall.TIOCGPTN, uintptr(unsafe.Pointer(&n))); errno != 0 {
+		t.Skipf("no pseudo terminal number: %v", errno)
+	}
+	tty, err := os.OpenFile("/dev/pts/"+strconv.Itoa(int(n)), os.O_RDWR|syscall.O_NOCTTY, 0)
+	if err != nil {
+		t.Skipf("cannot open the pseudo terminal: %v", err)
+	}
+	t.Cleanup(func() { tty.Close() })
+	return tty
+}
+
+func TestIsTerminal(t *testing.T) {
+	tty := openPTY(t)
+	null, err := os.OpenFile(os.DevNull, os.O_WRONLY, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer null.Close()
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	defer w.Close()
+	file, err := os.Create(t.TempDir() + "/out")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+
+	for _, tt := range []struct {
+		name string
+		f    *os.File
+		want bool
+	}{
+		{"terminal", tty, true},
+		{"null device", null, false},
+		{"pipe", w, false},
+		{"file", file, false},
+	} {
+		if got := isTerminal(tt.f); got != tt.want {
+			t.Errorf("%s: isTerminal = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+// The decode guard refuses to write binary data to a terminal on stdout.
+func TestDecodeTerminalGuard(t *testing.T) {
+	encoded, _, code := runMain(t, "", "\x1b[2J", "-q")
+	if code != 0 {
+		t.Fatal("cannot encode the test data")
+	}
+	tests := []struct {
+		name     string
+		args     []string
+		wantCode int
+	}{
+		{"decode", []string{"-d"}, 1},
+		{"forced", []string{"-d", "-force"}, 0},
+		{"as hex", []string{"-d", "-to-hex"}, 0},
+		{"encode", nil, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tty := openPTY(t)
+			cmd := exec.Command(os.Args[0], append(tt.args, "-q")...)
+			cmd.Env = append(os.Environ(), "C30_TEST_MAIN=1")
+			cmd.Stdin = strings.NewReader(encoded)
+			var stderr bytes.Buffer
+			cmd.Stdout, cmd.Stderr = tty, &stderr
+			err := cmd.Run()
+			var exit *exec.ExitError
+			code := 0
+			switch {
+			case errors.As(err, &exit):
+				code = exit.ExitCode()
+			case err != nil:
+				t.Fatal(err)
+			}
+			if code != tt.wantCode {
+				t.Fatalf("exit %d, want %d: %s", code, tt.wantCode, stderr.String())
+			}
+			if code != 0 && !strings.Contains(stderr.String(), "-force") {
+				t.Errorf("message does not mention -force: %s", stderr.String())
+			}
+		})
+	}
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// A body that stalls mid-transfer is abandoned once the budget runs out,
+// and the request is cancelled rather than left hanging.
+func TestOpenURLStalledBody(t *testing.T) {
+	resetBudget(t)
+	cancelled := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("ab"))
+		w.(http.Flusher).Flush()
+		<-r.Context().Done()
+		close(cancelled)
+	}))
+	defer server.Close()
+
+	encodeMap, _, err := createMaps(defaultAlphabet)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := encodeString(t, []byte("ab"), encodeMap, encodeOptions{spb: 2})
+
+	startBudget(100 * time.Millisecond)
+	body, err := openURL(server.URL, 100*time.Millisecond)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer body.Close()
+	var out bytes.Buffer
+	writer := bufio.NewWriter(&out)
+	done := make(chan error, 1)
+	go func() {
+		done <- encode(bufio.NewReader(newBudgetReader(body)), writer, encodeMap, encodeOptions{spb: 2})
+	}()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("still reading 5s after a budget of 100ms")
+	}
+	writer.Flush()
+	if out.String() != want {
+		t.Errorf("output %q, want %q", out.String(), want)
+	}
+	select {
+	case <-cancelled:
+	case <-time.After(5 * time.Second):
+		t.Error("request not cancelled")
+	}
+}
+
+func TestOpenURLErrors(t *testing.T) {
+	server := httptest.NewServer(http.NotFoundHandler())
+	defer server.Close()
+	for _, u := range []string{server.URL, "ftp://example.com/file", "://"} {
+		if body, err := openURL(u, 0); err == nil {
+			body.Close()
+			t.Errorf("%s: no error", u)
+		}
+	}
+}